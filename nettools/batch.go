@@ -0,0 +1,311 @@
+package nettools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const defaultBatchWorkers = 10
+
+// BatchOptions 表示批量执行的配置
+type BatchOptions struct {
+	Workers int  // 并发数，默认 10
+	PerHost int  // 每个主机的最大并发，<=0 表示不限制
+	Ordered bool // Stream 是否按提交顺序输出结果
+
+	// Handler 在工作协程中处理响应，返回的错误计入结果；
+	// 为空时读取完整响应体到 BatchResult.Body。两种情况下响应体都会被关闭
+	Handler func(r *Req, resp *http.Response) error
+}
+
+// BatchResult 表示批量执行中单个请求的结果
+type BatchResult struct {
+	Index    int // 请求的提交顺序
+	Req      *Req
+	Response *http.Response // 响应体已关闭，仅用于读取状态码和响应头
+	Body     []byte         // 未设置 Handler 时的响应体
+	Err      error
+}
+
+// BatchError 汇总批量执行中失败的请求
+type BatchError struct {
+	Total  int
+	Failed []BatchResult
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("批量请求失败 %d/%d，首个错误: %v", len(e.Failed), e.Total, e.Failed[0].Err)
+}
+
+// Unwrap 支持通过 errors.Is/As 检查任意一个失败原因
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, res := range e.Failed {
+		errs = append(errs, res.Err)
+	}
+	return errs
+}
+
+// Batch 表示带并发限制的批量请求执行器
+type Batch struct {
+	opts     BatchOptions
+	mu       sync.Mutex
+	hosts    map[string]int // 各主机正在执行的请求数
+	released chan struct{}  // 有主机名额释放时关闭并替换
+}
+
+// NewBatch 创建批量执行器，同一个执行器的主机并发限制在多次执行间共享
+func NewBatch(opts BatchOptions) *Batch {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultBatchWorkers
+	}
+	return &Batch{opts: opts, hosts: make(map[string]int), released: make(chan struct{})}
+}
+
+// Run 执行所有请求并按提交顺序返回结果，存在失败时同时返回 *BatchError；
+// ctx 取消后未开始的请求以 ctx.Err() 作为结果
+func (b *Batch) Run(ctx context.Context, reqs []*Req) ([]BatchResult, error) {
+	in := make(chan *Req)
+	go func() {
+		defer close(in)
+		for _, r := range reqs {
+			select {
+			case in <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make([]BatchResult, len(reqs))
+	done := make([]bool, len(reqs))
+	for res := range b.Stream(ctx, in) {
+		results[res.Index] = res
+		done[res.Index] = true
+	}
+
+	batchErr := &BatchError{Total: len(reqs)}
+	for i := range results {
+		if !done[i] {
+			results[i] = BatchResult{Index: i, Req: reqs[i], Err: ctx.Err()}
+		}
+		if results[i].Err != nil {
+			batchErr.Failed = append(batchErr.Failed, results[i])
+		}
+	}
+	if len(batchErr.Failed) > 0 {
+		return results, batchErr
+	}
+	return results, nil
+}
+
+// Stream 从 in 中读取请求并发执行，结果在全部完成或 ctx 取消后关闭输出通道
+func (b *Batch) Stream(ctx context.Context, in <-chan *Req) <-chan BatchResult {
+	jobs := make(chan batchJob)
+	results := make(chan BatchResult)
+	out := make(chan BatchResult)
+
+	// 分发请求：先取得主机名额再交给工作协程，
+	// 主机并发已满的请求暂存等待，不占用工作协程
+	go func() {
+		defer close(jobs)
+		var ready, waiting []batchJob
+		defer func() {
+			// ctx 取消时归还已取得但未执行的名额
+			for _, j := range ready {
+				b.releaseHost(j.host)
+			}
+		}()
+
+		src := in
+		for index := 0; src != nil || len(ready) > 0 || len(waiting) > 0; {
+			// 先取通知通道再重试，避免错过两者之间的释放
+			var wake <-chan struct{}
+			if len(waiting) > 0 {
+				wake = b.releasedChan()
+				pending := waiting[:0]
+				for _, j := range waiting {
+					if b.tryAcquireHost(j.host) {
+						ready = append(ready, j)
+					} else {
+						pending = append(pending, j)
+					}
+				}
+				waiting = pending
+			}
+
+			var send chan<- batchJob
+			var next batchJob
+			if len(ready) > 0 {
+				send, next = jobs, ready[0]
+			}
+			// 暂存的请求不超过工作协程数
+			recv := src
+			if len(ready)+len(waiting) >= b.opts.Workers {
+				recv = nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+			case send <- next:
+				ready = ready[1:]
+			case r, ok := <-recv:
+				if !ok {
+					src = nil
+					continue
+				}
+				j := batchJob{index: index, req: r, host: b.hostKey(r.Url)}
+				index++
+				if b.tryAcquireHost(j.host) {
+					ready = append(ready, j)
+				} else {
+					waiting = append(waiting, j)
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := b.execute(ctx, j.index, j.req)
+				b.releaseHost(j.host)
+				results <- res
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// 输出结果，ctx 取消后继续消费 results 以免工作协程阻塞
+	go func() {
+		defer close(out)
+		emit := func(res BatchResult) {
+			select {
+			case out <- res:
+			case <-ctx.Done():
+			}
+		}
+		if !b.opts.Ordered {
+			for res := range results {
+				emit(res)
+			}
+			return
+		}
+
+		pending := make(map[int]BatchResult)
+		next := 0
+		for res := range results {
+			pending[res.Index] = res
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				emit(ready)
+				next++
+			}
+		}
+	}()
+
+	return out
+}
+
+type batchJob struct {
+	index int
+	req   *Req
+	host  string
+}
+
+func (b *Batch) execute(ctx context.Context, index int, r *Req) BatchResult {
+	res := BatchResult{Index: index, Req: r}
+	if err := ctx.Err(); err != nil {
+		res.Err = err
+		return res
+	}
+
+	// 同一个 Req 可能在列表中出现多次，使用副本设置 ctx，不修改调用方的 Req；
+	// Req 自带 ctx 时保留其值与截止时间，批次取消时同样取消
+	parent := ctx
+	if r.Ctx != nil {
+		parent = r.Ctx
+	}
+	callCtx, cancel := context.WithCancel(parent)
+	defer cancel()
+	if r.Ctx != nil {
+		defer context.AfterFunc(ctx, cancel)()
+	}
+	resp, err := r.Clone().SetContext(callCtx).Do()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer resp.Body.Close()
+
+	res.Response = resp
+	if b.opts.Handler != nil {
+		res.Err = b.opts.Handler(r, resp)
+	} else {
+		res.Body, res.Err = io.ReadAll(resp.Body)
+	}
+	return res
+}
+
+// hostKey 返回用于主机并发限制的键，未限制或 URL 无法解析时为空，由 Do 报告解析错误
+func (b *Batch) hostKey(rawURL string) string {
+	if b.opts.PerHost <= 0 {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// tryAcquireHost 尝试占用主机并发名额，不阻塞
+func (b *Batch) tryAcquireHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hosts[host] >= b.opts.PerHost {
+		return false
+	}
+	b.hosts[host]++
+	return true
+}
+
+// releaseHost 归还主机并发名额并通知等待中的分发协程
+func (b *Batch) releaseHost(host string) {
+	if host == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hosts[host]--; b.hosts[host] <= 0 {
+		delete(b.hosts, host)
+	}
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+func (b *Batch) releasedChan() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.released
+}
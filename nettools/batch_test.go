@@ -0,0 +1,163 @@
+package nettools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBatchRun(t *testing.T) {
+	var active, maxActive atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte(r.URL.Query().Get("i")))
+	}))
+	defer srv.Close()
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := ln.Addr().String()
+	ln.Close()
+
+	var reqs []*Req
+	for i := 0; i < 20; i++ {
+		reqs = append(reqs, NewRequest().SetUrl(srv.URL).Get().SetParams(map[string]interface{}{"i": i}))
+	}
+	reqs = append(reqs, NewRequest().SetUrl("http://"+dead).Get())
+
+	results, err := NewBatch(BatchOptions{Workers: 8, PerHost: 3}).Run(context.Background(), reqs)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 1 || batchErr.Failed[0].Index != 20 {
+		t.Fatalf("expected one aggregated failure, got %v", err)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("aggregated error should unwrap to the cause: %v", err)
+	}
+	for i, res := range results[:20] {
+		if res.Err != nil || string(res.Body) != fmt.Sprint(i) {
+			t.Fatalf("result %d: body=%q err=%v", i, res.Body, res.Err)
+		}
+	}
+	if got := maxActive.Load(); got > 3 {
+		t.Fatalf("per-host cap exceeded: %d", got)
+	}
+}
+
+func TestBatchStreamOrderedAndCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 越靠前的请求越慢，检验有序输出
+		if r.URL.Query().Get("i") == "0" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	in := make(chan *Req)
+	go func() {
+		defer close(in)
+		for i := 0; i < 5; i++ {
+			in <- NewRequest().SetUrl(srv.URL).Get().SetParams(map[string]interface{}{"i": i})
+		}
+	}()
+	next := 0
+	for res := range NewBatch(BatchOptions{Workers: 5, Ordered: true}).Stream(context.Background(), in) {
+		if res.Index != next || res.Err != nil {
+			t.Fatalf("unexpected result %d (want %d): %v", res.Index, next, res.Err)
+		}
+		next++
+	}
+	if next != 5 {
+		t.Fatalf("got %d results", next)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := NewBatch(BatchOptions{}).Run(ctx, []*Req{NewRequest().SetUrl(srv.URL).Get()})
+	if !errors.Is(err, context.Canceled) || !errors.Is(results[0].Err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestBatchSharedReq(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// 同一个 Req 重复提交，不应修改调用方的 Req
+	req := NewRequest().SetUrl(srv.URL).Get()
+	results, err := NewBatch(BatchOptions{Workers: 4}).Run(context.Background(), []*Req{req, req, req, req})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		if res.Req != req || string(res.Body) != "ok" {
+			t.Fatalf("unexpected result %+v", res)
+		}
+	}
+	if req.Ctx != nil {
+		t.Fatal("batch leaked its context into the caller's Req")
+	}
+}
+
+func TestBatchCancelReqWithContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	// Req 自带 ctx 时批次取消仍需中断请求
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := NewRequest().SetUrl(srv.URL).Get().SetContext(context.Background())
+	start := time.Now()
+	results, _ := NewBatch(BatchOptions{}).Run(ctx, []*Req{req})
+	if results[0].Err == nil || time.Since(start) > 2*time.Second {
+		t.Fatalf("request was not cancelled with the batch: %v", results[0].Err)
+	}
+	if req.Ctx != context.Background() {
+		t.Fatal("batch replaced the caller's context")
+	}
+}
+
+func TestBatchPerHostDoesNotHoldWorkers(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fast.Close()
+
+	// 第二个慢请求等待主机名额时，空闲的工作协程应先执行其他主机的请求
+	reqs := []*Req{NewRequest().SetUrl(slow.URL).Get(), NewRequest().SetUrl(slow.URL).Get(), NewRequest().SetUrl(fast.URL).Get()}
+	in := make(chan *Req, len(reqs))
+	for _, r := range reqs {
+		in <- r
+	}
+	close(in)
+	var order []int
+	for res := range NewBatch(BatchOptions{Workers: 2, PerHost: 1}).Stream(context.Background(), in) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		order = append(order, res.Index)
+	}
+	if len(order) != 3 || order[0] != 2 {
+		t.Fatalf("fast host was blocked behind a waiting request: %v", order)
+	}
+}
@@ -0,0 +1,314 @@
+package nettools
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+	defaultBreakerMaxHosts         = 10000 // 记录状态的主机数上限
+)
+
+// CircuitState 表示熔断器状态
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrCircuitOpen 表示请求被熔断器拦截
+var ErrCircuitOpen = errors.New("熔断器已打开")
+
+// CircuitOpenError 表示被熔断拦截的请求，可用 errors.Is(err, ErrCircuitOpen) 判断
+type CircuitOpenError struct {
+	Host       string
+	State      CircuitState
+	RetryAfter time.Duration // 距离进入半开状态的剩余时间
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v: %s (%s)，%s 后重试", ErrCircuitOpen, e.Host, e.State, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Unwrap() error { return ErrCircuitOpen }
+
+// CircuitBreakerConfig 表示熔断器配置
+type CircuitBreakerConfig struct {
+	FailureThreshold    int           // 连续失败多少次后打开，默认 5
+	Cooldown            time.Duration // 打开后多久进入半开，默认 30s
+	HalfOpenMaxRequests int           // 半开状态允许同时探测的请求数，默认 1
+	SuccessThreshold    int           // 半开状态连续成功多少次后关闭，默认 1
+	MaxHosts            int           // 记录状态的主机数上限，超出时淘汰最久未使用的关闭主机，默认 10000
+
+	// FailureStatuses 视为失败的状态码，为空时 5xx 视为失败
+	FailureStatuses []int
+	// FailureErrorKinds 视为失败的错误类型（见 ErrorKind），为空时除取消外的错误都视为失败
+	FailureErrorKinds []string
+	// IsFailure 自定义失败判定，设置后忽略 FailureStatuses/FailureErrorKinds
+	IsFailure func(resp *http.Response, err error) bool
+}
+
+// CircuitStatus 表示单个主机的熔断状态，可用于健康检查接口
+type CircuitStatus struct {
+	Host     string
+	State    CircuitState
+	Failures int // 当前连续失败次数
+	OpenedAt time.Time
+}
+
+// CircuitBreaker 表示按主机区分的熔断器，可在多个 Req 之间共享
+type CircuitBreaker struct {
+	cfg   CircuitBreakerConfig
+	mu    sync.Mutex
+	hosts map[string]*hostCircuit
+}
+
+type hostCircuit struct {
+	state     CircuitState
+	failures  int
+	successes int
+	inflight  int // 半开状态下正在进行的探测请求
+	openedAt  time.Time
+	lastUsed  time.Time
+	// generation 在每次状态切换时递增，放行时记录，状态切换前放行的请求结果不再计入
+	generation uint64
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultBreakerCooldown
+	}
+	if cfg.HalfOpenMaxRequests <= 0 {
+		cfg.HalfOpenMaxRequests = 1
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.MaxHosts <= 0 {
+		cfg.MaxHosts = defaultBreakerMaxHosts
+	}
+	return &CircuitBreaker{cfg: cfg, hosts: make(map[string]*hostCircuit)}
+}
+
+// State 返回主机当前的熔断状态
+func (b *CircuitBreaker) State(host string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuit, ok := b.hosts[strings.ToLower(host)]
+	if !ok {
+		return CircuitClosed
+	}
+	b.refresh(circuit)
+	return circuit.state
+}
+
+// Status 返回所有主机的熔断状态
+func (b *CircuitBreaker) Status() []CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := make([]CircuitStatus, 0, len(b.hosts))
+	for host, circuit := range b.hosts {
+		b.refresh(circuit)
+		status = append(status, CircuitStatus{
+			Host:     host,
+			State:    circuit.state,
+			Failures: circuit.failures,
+			OpenedAt: circuit.openedAt,
+		})
+	}
+	return status
+}
+
+// Reset 将主机恢复为关闭状态
+func (b *CircuitBreaker) Reset(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.hosts, strings.ToLower(host))
+}
+
+// refresh 冷却时间到达后将打开状态切换为半开
+func (b *CircuitBreaker) refresh(circuit *hostCircuit) {
+	if circuit.state == CircuitOpen && time.Since(circuit.openedAt) >= b.cfg.Cooldown {
+		circuit.setState(CircuitHalfOpen)
+		circuit.successes = 0
+		circuit.inflight = 0
+	}
+}
+
+func (c *hostCircuit) setState(state CircuitState) {
+	c.state = state
+	c.generation++
+}
+
+// evict 淘汰关闭且没有连续失败的主机，仍超出上限时按最久未使用的顺序淘汰到上限的 90%，
+// 打开与半开的主机最后淘汰
+func (b *CircuitBreaker) evict() {
+	for host, circuit := range b.hosts {
+		if circuit.state == CircuitClosed && circuit.failures == 0 {
+			delete(b.hosts, host)
+		}
+	}
+	if len(b.hosts) < b.cfg.MaxHosts {
+		return
+	}
+	hosts := make([]string, 0, len(b.hosts))
+	for host := range b.hosts {
+		hosts = append(hosts, host)
+	}
+	slices.SortFunc(hosts, func(x, y string) int {
+		ca, cb := b.hosts[x], b.hosts[y]
+		if oa, ob := ca.state != CircuitClosed, cb.state != CircuitClosed; oa != ob {
+			if oa {
+				return 1
+			}
+			return -1
+		}
+		return ca.lastUsed.Compare(cb.lastUsed)
+	})
+	for _, host := range hosts[:len(hosts)-b.cfg.MaxHosts*9/10] {
+		delete(b.hosts, host)
+	}
+}
+
+// allow 判断请求能否放行，放行时返回用于回报结果的函数
+func (b *CircuitBreaker) allow(host string) (func(failed bool), error) {
+	host = strings.ToLower(host)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	circuit, ok := b.hosts[host]
+	if !ok {
+		if len(b.hosts) >= b.cfg.MaxHosts {
+			b.evict()
+		}
+		circuit = &hostCircuit{}
+		b.hosts[host] = circuit
+	}
+	circuit.lastUsed = time.Now()
+	b.refresh(circuit)
+
+	switch circuit.state {
+	case CircuitOpen:
+		return nil, &CircuitOpenError{
+			Host:       host,
+			State:      CircuitOpen,
+			RetryAfter: b.cfg.Cooldown - time.Since(circuit.openedAt),
+		}
+	case CircuitHalfOpen:
+		if circuit.inflight >= b.cfg.HalfOpenMaxRequests {
+			return nil, &CircuitOpenError{Host: host, State: CircuitHalfOpen}
+		}
+		circuit.inflight++
+	}
+
+	generation := circuit.generation
+	return func(failed bool) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.record(circuit, generation, failed)
+	}, nil
+}
+
+// record 回报请求结果，放行后状态已切换的结果直接忽略，
+// 避免关闭状态下放行的请求在半开期间返回并被当作探测结果
+func (b *CircuitBreaker) record(circuit *hostCircuit, generation uint64, failed bool) {
+	if generation != circuit.generation {
+		return
+	}
+	if circuit.state == CircuitHalfOpen && circuit.inflight > 0 {
+		circuit.inflight--
+	}
+
+	if failed {
+		circuit.failures++
+		if circuit.state == CircuitHalfOpen || circuit.failures >= b.cfg.FailureThreshold {
+			circuit.setState(CircuitOpen)
+			circuit.openedAt = time.Now()
+		}
+		return
+	}
+
+	circuit.failures = 0
+	if circuit.state == CircuitHalfOpen {
+		circuit.successes++
+		if circuit.successes >= b.cfg.SuccessThreshold {
+			circuit.setState(CircuitClosed)
+		}
+	}
+}
+
+// isFailure 按配置判定请求结果是否计为失败
+func (b *CircuitBreaker) isFailure(resp *http.Response, err error) bool {
+	if b.cfg.IsFailure != nil {
+		return b.cfg.IsFailure(resp, err)
+	}
+
+	if err != nil {
+		kind := ErrorKind(err)
+		if len(b.cfg.FailureErrorKinds) == 0 {
+			return kind != ErrorKindCanceled
+		}
+		for _, k := range b.cfg.FailureErrorKinds {
+			if k == kind {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(b.cfg.FailureStatuses) == 0 {
+		return resp.StatusCode >= 500
+	}
+	for _, code := range b.cfg.FailureStatuses {
+		if code == resp.StatusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Req) SetCircuitBreaker(breaker *CircuitBreaker) *Req {
+	r.CircuitBreaker = breaker
+	return r
+}
+
+// circuitBreakerMiddleware 在熔断打开时直接返回 CircuitOpenError
+func circuitBreakerMiddleware(breaker *CircuitBreaker) Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			done, err := breaker.allow(req.URL.Host)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next(req)
+			done(breaker.isFailure(resp, err))
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package nettools
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, Cooldown: 100 * time.Millisecond})
+	do := func() error {
+		resp, err := NewRequest().SetUrl(srv.URL).Get().SetCircuitBreaker(breaker).Do()
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := do(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if state := breaker.State(host); state != CircuitOpen {
+		t.Fatalf("expected open, got %s", state)
+	}
+
+	err := do()
+	var openErr *CircuitOpenError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.RetryAfter <= 0 {
+		t.Fatalf("expected circuit open error, got %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("open circuit should short-circuit, server hits = %d", hits.Load())
+	}
+
+	// 冷却后半开，探测失败重新打开
+	time.Sleep(120 * time.Millisecond)
+	if state := breaker.State(host); state != CircuitHalfOpen {
+		t.Fatalf("expected half-open, got %s", state)
+	}
+	if err := do(); err != nil {
+		t.Fatal(err)
+	}
+	if state := breaker.State(host); state != CircuitOpen {
+		t.Fatalf("failed probe should reopen, got %s", state)
+	}
+
+	// 探测成功后关闭
+	healthy.Store(true)
+	time.Sleep(120 * time.Millisecond)
+	if err := do(); err != nil {
+		t.Fatal(err)
+	}
+	status := breaker.Status()
+	if len(status) != 1 || status[0].State != CircuitClosed || status[0].Failures != 0 {
+		t.Fatalf("expected closed circuit, got %+v", status)
+	}
+}
+
+func TestCircuitBreakerClassification(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureStatuses:   []int{http.StatusTooManyRequests},
+		FailureErrorKinds: []string{ErrorKindTimeout},
+	})
+	if breaker.isFailure(&http.Response{StatusCode: 500}, nil) {
+		t.Fatal("500 should not count when FailureStatuses is set")
+	}
+	if !breaker.isFailure(&http.Response{StatusCode: 429}, nil) {
+		t.Fatal("429 should count as failure")
+	}
+	if breaker.isFailure(nil, errors.New("boom")) {
+		t.Fatal("other errors should not count when FailureErrorKinds is set")
+	}
+}
+
+func TestCircuitBreakerIgnoresRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2})
+	limiter := NewRateLimiter().SetPerHost(0.1, 1).SetFailFast(true)
+	for i := 0; i < 4; i++ {
+		_, err := NewRequest().SetUrl(srv.URL).Get().
+			SetRateLimiter(limiter).SetCircuitBreaker(breaker).DoAndGetBody()
+		if i > 0 && !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("request %d: expected rate limit error, got %v", i, err)
+		}
+	}
+	if state := breaker.State(host); state != CircuitClosed {
+		t.Fatalf("throttled requests opened the circuit: %s", state)
+	}
+}
+
+func TestCircuitBreakerStaleResult(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: 50 * time.Millisecond})
+	slow, err := breaker.allow("a.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, _ := breaker.allow("a.test")
+	fast(true)
+	time.Sleep(60 * time.Millisecond)
+	if state := breaker.State("a.test"); state != CircuitHalfOpen {
+		t.Fatalf("expected half-open, got %s", state)
+	}
+
+	// 关闭状态下放行的请求在半开期间返回，不能当作探测结果
+	slow(false)
+	if state := breaker.State("a.test"); state != CircuitHalfOpen {
+		t.Fatalf("stale success changed state to %s", state)
+	}
+	probe, err := breaker.allow("a.test")
+	if err != nil {
+		t.Fatalf("probe slot taken by stale result: %v", err)
+	}
+	probe(false)
+	if state := breaker.State("a.test"); state != CircuitClosed {
+		t.Fatalf("expected closed after probe, got %s", state)
+	}
+}
+
+func TestCircuitBreakerEviction(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Minute, MaxHosts: 10})
+	for i := 0; i < 2; i++ {
+		done, _ := breaker.allow("open.test")
+		done(true)
+	}
+	// 每个主机都有一次失败，不会被直接清理，只能按最久未使用淘汰
+	for i := 0; i < 100; i++ {
+		done, err := breaker.allow(fmt.Sprintf("host%d.test", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		done(true)
+	}
+	if n := len(breaker.Status()); n > 10 {
+		t.Fatalf("expected at most 10 hosts, got %d", n)
+	}
+	if state := breaker.State("open.test"); state != CircuitOpen {
+		t.Fatalf("open circuit was evicted: %s", state)
+	}
+}
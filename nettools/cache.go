@@ -0,0 +1,618 @@
+package nettools
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 缓存结果，通过 GetCacheStatus 获取
+const (
+	CacheHit         = "hit"         // 直接使用缓存，未发送请求
+	CacheRevalidated = "revalidated" // 条件请求返回 304，使用缓存的响应体
+	CacheMiss        = "miss"        // 从服务器获取了完整响应
+)
+
+// CacheStorage 表示缓存的存储后端，实现需支持并发调用
+type CacheStorage interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// CacheStats 表示缓存命中统计
+type CacheStats struct {
+	Hits          int64
+	Misses        int64
+	Revalidations int64 // 条件请求得到 304 的次数
+	Stores        int64 // 写入缓存的次数
+}
+
+// 未设置 MaxBodySize 时单个缓存条目的响应体上限
+const defaultCacheMaxEntrySize = 10 << 20
+
+// Cache 表示遵循 RFC 9111 的私有客户端缓存，可在多个 Req 之间共享。
+// 只缓存未经重定向的 GET/HEAD 响应，不处理 Range 请求；
+// 带 Authorization 的请求只有响应显式允许共享时才存储，避免不同凭据的 Req 互相读到对方的响应
+type Cache struct {
+	storage      CacheStorage
+	maxEntrySize int64
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	revalidations atomic.Int64
+	stores        atomic.Int64
+}
+
+// NewCache 使用指定的存储后端创建缓存
+func NewCache(storage CacheStorage) *Cache {
+	return &Cache{storage: storage, maxEntrySize: defaultCacheMaxEntrySize}
+}
+
+// SetMaxEntrySize 设置单个条目的响应体上限，超过的响应不存储，<=0 时使用默认值 10MiB；
+// Req 设置了更小的 MaxBodySize 时以后者为准
+func (c *Cache) SetMaxEntrySize(n int64) *Cache {
+	if n <= 0 {
+		n = defaultCacheMaxEntrySize
+	}
+	c.maxEntrySize = n
+	return c
+}
+
+// Stats 返回缓存命中统计
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Revalidations: c.revalidations.Load(),
+		Stores:        c.stores.Load(),
+	}
+}
+
+// Invalidate 删除 URL 对应的缓存
+func (c *Cache) Invalidate(rawURL string) {
+	c.storage.Delete(http.MethodGet + " " + rawURL)
+	c.storage.Delete(http.MethodHead + " " + rawURL)
+}
+
+func (r *Req) SetCache(cache *Cache) *Req {
+	r.Cache = cache
+	return r
+}
+
+// GetCacheStatus 返回响应的缓存结果，未经过缓存时返回空字符串
+func GetCacheStatus(resp *http.Response) string {
+	if resp == nil || resp.Request == nil {
+		return ""
+	}
+	status, _ := resp.Request.Context().Value(cacheStatusKey{}).(string)
+	return status
+}
+
+type cacheStatusKey struct{}
+
+func withCacheStatus(req *http.Request, status string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), cacheStatusKey{}, status))
+}
+
+// cacheMiddleware 在发送前查找缓存，并按响应头存储或刷新缓存；
+// 响应体超过 limit 或条目上限的响应不存储，limit <=0 时只受条目上限限制
+func cacheMiddleware(cache *Cache, limit int64) Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return cache.do(req, next, limit)
+		}
+	}
+}
+
+func (c *Cache) do(req *http.Request, next DoFunc, limit int64) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp, err := next(req)
+		// 不安全方法成功后使对应资源及 Location/Content-Location 指向的同源资源失效（RFC 9111 4.4）
+		if err == nil && resp.StatusCode < 400 && req.Method != http.MethodOptions && req.Method != http.MethodTrace {
+			c.Invalidate(req.URL.String())
+			for _, name := range []string{"Location", "Content-Location"} {
+				if target := sameOriginRef(req.URL, resp.Header.Get(name)); target != "" {
+					c.Invalidate(target)
+				}
+			}
+		}
+		return resp, err
+	}
+	if req.Header.Get("Range") != "" {
+		return next(req)
+	}
+
+	key := req.Method + " " + req.URL.String()
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-cache"]; !ok && req.Header.Get("Cache-Control") == "" &&
+		strings.Contains(strings.ToLower(req.Header.Get("Pragma")), "no-cache") {
+		reqCC["no-cache"] = ""
+	}
+
+	entry := c.load(key, req)
+	now := time.Now()
+	if entry != nil && entry.usable(reqCC, now) {
+		c.hits.Add(1)
+		return entry.response(req, entry.age(now), CacheHit), nil
+	}
+	if _, ok := reqCC["only-if-cached"]; ok {
+		c.misses.Add(1)
+		return &http.Response{
+			Status:     "504 Gateway Timeout",
+			StatusCode: http.StatusGatewayTimeout,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    withCacheStatus(req, CacheMiss),
+		}, nil
+	}
+
+	// 使用缓存中的校验器发送条件请求，调用方自行设置的校验器优先
+	outReq := req
+	conditional := false
+	if entry != nil && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == "" {
+		etag, lastModified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outReq = req.Clone(req.Context())
+			if etag != "" {
+				outReq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outReq.Header.Set("If-Modified-Since", lastModified)
+			}
+			conditional = true
+		}
+	}
+
+	requestTime := time.Now()
+	resp, err := next(outReq)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := time.Now()
+
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		entry.refresh(resp.Header, requestTime, responseTime)
+		c.save(key, entry)
+		c.revalidations.Add(1)
+		return entry.response(req, 0, CacheRevalidated), nil
+	}
+
+	c.misses.Add(1)
+	resp.Request = withCacheStatus(resp.Request, CacheMiss)
+	// 经过重定向的响应属于另一个 URL，不以当前 URL 缓存
+	if resp.Request.URL.String() != req.URL.String() || !storable(req, reqCC, resp) {
+		return resp, nil
+	}
+
+	// 超过上限的响应不存储，最多多读 1 字节用于判断，已读内容与剩余部分原样交给调用方，
+	// 超过 MaxBodySize 时由 Do 返回 BodyTooLargeError；缓存的是压缩内容，解压后的大小同样由 Do 检查
+	if limit <= 0 || limit > c.maxEntrySize {
+		limit = c.maxEntrySize
+	}
+	if req.Method != http.MethodHead && resp.ContentLength > limit {
+		return resp, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("读取响应体失败: %w", err)
+	}
+	if int64(len(body)) > limit {
+		resp.Body = restoreBody(bytes.NewReader(body), resp.Body)
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	contentLength := int64(len(body))
+	if req.Method == http.MethodHead {
+		contentLength = resp.ContentLength
+	}
+	c.save(key, &cacheEntry{
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Proto:         resp.Proto,
+		Header:        resp.Header.Clone(),
+		Body:          body,
+		ContentLength: contentLength,
+		Vary:          varyValues(resp.Header, req.Header),
+		RequestTime:   requestTime,
+		ResponseTime:  responseTime,
+	})
+	c.stores.Add(1)
+	return resp, nil
+}
+
+// load 读取缓存条目，Vary 不匹配时视为未命中
+func (c *Cache) load(key string, req *http.Request) *cacheEntry {
+	data, ok := c.storage.Get(key)
+	if !ok {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		c.storage.Delete(key)
+		return nil
+	}
+	for name, value := range entry.Vary {
+		if strings.Join(req.Header.Values(name), ", ") != value {
+			return nil
+		}
+	}
+	return &entry
+}
+
+func (c *Cache) save(key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	c.storage.Set(key, data)
+}
+
+// cacheEntry 表示存储的响应
+type cacheEntry struct {
+	StatusCode    int
+	Status        string
+	Proto         string
+	Header        http.Header
+	Body          []byte
+	ContentLength int64             // HEAD 响应没有响应体，保存响应头中的长度
+	Vary          map[string]string // Vary 中各请求头在存储时的取值
+	RequestTime   time.Time
+	ResponseTime  time.Time
+}
+
+// 没有显式过期时间时允许启发式计算新鲜度的状态码
+var heuristicStatuses = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// storable 判断响应是否可以存储
+func storable(req *http.Request, reqCC cacheControl, resp *http.Response) bool {
+	if _, ok := reqCC["no-store"]; ok {
+		return false
+	}
+	respCC := parseCacheControl(resp.Header)
+	if _, ok := respCC["no-store"]; ok {
+		return false
+	}
+	if resp.StatusCode == http.StatusPartialContent || resp.StatusCode < 200 {
+		return false
+	}
+	for _, name := range headerTokens(resp.Header, "Vary") {
+		if name == "*" {
+			return false
+		}
+	}
+
+	_, hasMaxAge := respCC["max-age"]
+	_, public := respCC["public"]
+	// 带凭据的响应只有显式允许共享时才存储（RFC 9111 3.5）
+	if req.Header.Get("Authorization") != "" {
+		_, sMaxAge := respCC["s-maxage"]
+		_, mustRevalidate := respCC["must-revalidate"]
+		if !public && !sMaxAge && !mustRevalidate {
+			return false
+		}
+	}
+	if hasMaxAge || public || resp.Header.Get("Expires") != "" {
+		return true
+	}
+	// 没有新鲜度信息时，只有带校验器的响应值得存储
+	validator := resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+	return heuristicStatuses[resp.StatusCode] && validator
+}
+
+// usable 判断缓存能否不经验证直接使用
+func (e *cacheEntry) usable(reqCC cacheControl, now time.Time) bool {
+	respCC := parseCacheControl(e.Header)
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	if _, ok := respCC["no-cache"]; ok {
+		return false
+	}
+
+	age, lifetime := e.age(now), e.lifetime()
+	if maxAge, ok := reqCC.duration("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.duration("min-fresh"); ok {
+		age += minFresh
+	}
+	if age < lifetime {
+		return true
+	}
+
+	// 已过期的响应只有在请求允许且服务端未禁止时才能使用
+	if _, ok := respCC["must-revalidate"]; ok {
+		return false
+	}
+	raw, ok := reqCC["max-stale"]
+	if !ok {
+		return false
+	}
+	if raw == "" {
+		return true
+	}
+	maxStale, ok := reqCC.duration("max-stale")
+	return ok && age-lifetime <= maxStale
+}
+
+// lifetime 计算新鲜期：max-age > Expires > Last-Modified 启发式（10%）
+func (e *cacheEntry) lifetime() time.Duration {
+	if maxAge, ok := parseCacheControl(e.Header).duration("max-age"); ok {
+		return maxAge
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return at.Sub(e.date())
+	}
+	if heuristicStatuses[e.StatusCode] {
+		if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+			if delta := e.date().Sub(lastModified); delta > 0 {
+				return delta / 10
+			}
+		}
+	}
+	return 0
+}
+
+func (e *cacheEntry) date() time.Time {
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return date
+	}
+	return e.ResponseTime
+}
+
+// age 按 RFC 9111 4.2.3 计算当前年龄
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	apparent := e.ResponseTime.Sub(e.date())
+	if apparent < 0 {
+		apparent = 0
+	}
+	corrected := e.ResponseTime.Sub(e.RequestTime)
+	if seconds, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		corrected += time.Duration(seconds) * time.Second
+	}
+	return max(apparent, corrected) + now.Sub(e.ResponseTime)
+}
+
+// refresh 使用 304 响应头更新缓存条目
+func (e *cacheEntry) refresh(header http.Header, requestTime, responseTime time.Time) {
+	for key, values := range header {
+		switch key {
+		case "Content-Length", "Content-Encoding", "Content-Range", "Transfer-Encoding":
+			continue
+		}
+		e.Header[key] = values
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+func (e *cacheEntry) response(req *http.Request, age time.Duration, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+
+	resp := &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         e.Proto,
+		Header:        header,
+		Body:          http.NoBody,
+		ContentLength: int64(len(e.Body)),
+		Request:       withCacheStatus(req, status),
+	}
+	if req.Method == http.MethodHead {
+		resp.ContentLength = e.ContentLength
+	}
+	resp.ProtoMajor, resp.ProtoMinor, _ = http.ParseHTTPVersion(e.Proto)
+	if req.Method != http.MethodHead && len(e.Body) > 0 {
+		resp.Body = io.NopCloser(bytes.NewReader(e.Body))
+	}
+	return resp
+}
+
+// sameOriginRef 将响应头中的 URI 引用解析为绝对 URL，与 base 不同源时返回空字符串
+func sameOriginRef(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	target, err := base.Parse(ref)
+	if err != nil || target.Scheme != base.Scheme || !strings.EqualFold(target.Host, base.Host) {
+		return ""
+	}
+	target.Fragment = ""
+	return target.String()
+}
+
+func varyValues(respHeader, reqHeader http.Header) map[string]string {
+	names := headerTokens(respHeader, "Vary")
+	if len(names) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		values[name] = strings.Join(reqHeader.Values(name), ", ")
+	}
+	return values
+}
+
+func headerTokens(header http.Header, key string) []string {
+	var tokens []string
+	for _, value := range header.Values(key) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// cacheControl 表示 Cache-Control 指令，键为小写指令名，值为去掉引号的参数
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, directive := range headerTokens(header, "Cache-Control") {
+		name, value, _ := strings.Cut(directive, "=")
+		cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return cc
+}
+
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	raw, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || seconds < 0 {
+		// 无法解析的值按最严格的 0 处理
+		return 0, true
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// 存储后端 ---------------------------------------------------
+
+// MemoryCache 表示按 LRU 淘汰的内存存储
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCache 创建内存存储，maxEntries <= 0 表示不限制条目数
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	m.ll.MoveToFront(elem)
+	return elem.Value.(*memoryItem).value, true
+}
+
+func (m *MemoryCache) Set(key string, value []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.items[key]; ok {
+		elem.Value.(*memoryItem).value = value
+		m.ll.MoveToFront(elem)
+		return
+	}
+	m.items[key] = m.ll.PushFront(&memoryItem{key: key, value: value})
+	if m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		oldest := m.ll.Back()
+		m.ll.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryItem).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.items[key]; ok {
+		m.ll.Remove(elem)
+		delete(m.items, key)
+	}
+}
+
+// Len 返回当前条目数
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+// DiskCache 表示以目录保存的持久化存储，每个条目对应一个文件
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache 创建磁盘存储，目录不存在时自动创建
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set 先写入临时文件再重命名，避免并发读取到不完整的内容
+func (d *DiskCache) Set(key string, value []byte) {
+	tmp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), d.path(key)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (d *DiskCache) Delete(key string) {
+	os.Remove(d.path(key))
+}
@@ -0,0 +1,194 @@
+package nettools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte("body" + r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	get := func(cache *Cache, path string, headers map[string]string) (string, string) {
+		t.Helper()
+		req := NewRequest().SetUrl(srv.URL + path).Get().SetCache(cache)
+		for k, v := range headers {
+			req.SetHeader(k, v)
+		}
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ReadResponseBody(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body), GetCacheStatus(resp)
+	}
+	expect := func(cache *Cache, path string, headers map[string]string, wantBody, wantStatus string, wantHits int32) {
+		t.Helper()
+		body, status := get(cache, path, headers)
+		if body != wantBody || status != wantStatus || hits.Load() != wantHits {
+			t.Fatalf("%s: body=%q status=%q server hits=%d, want %q %q %d",
+				path, body, status, hits.Load(), wantBody, wantStatus, wantHits)
+		}
+	}
+
+	cache := NewCache(NewMemoryCache(0))
+	expect(cache, "/fresh", nil, "body", CacheMiss, 1)
+	expect(cache, "/fresh", nil, "body", CacheHit, 1)
+	expect(cache, "/fresh", map[string]string{"Cache-Control": "no-cache"}, "body", CacheMiss, 2)
+
+	expect(cache, "/etag", nil, "body", CacheMiss, 3)
+	expect(cache, "/etag", nil, "body", CacheRevalidated, 4)
+
+	expect(cache, "/vary", map[string]string{"Accept-Language": "en"}, "bodyen", CacheMiss, 5)
+	expect(cache, "/vary", map[string]string{"Accept-Language": "en"}, "bodyen", CacheHit, 5)
+	expect(cache, "/vary", map[string]string{"Accept-Language": "fr"}, "bodyfr", CacheMiss, 6)
+
+	expect(cache, "/nostore", nil, "body", CacheMiss, 7)
+	expect(cache, "/nostore", nil, "body", CacheMiss, 8)
+
+	// 不安全方法使缓存失效
+	if _, err := NewRequest().SetUrl(srv.URL + "/fresh").Post().SetCache(cache).DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	expect(cache, "/fresh", nil, "body", CacheMiss, 10)
+
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Revalidations != 1 || stats.Misses != 8 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// 磁盘存储在不同 Cache 实例间保留
+	disk, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(NewCache(disk), "/fresh", nil, "body", CacheMiss, 11)
+	expect(NewCache(disk), "/fresh", nil, "body", CacheHit, 11)
+}
+
+func TestCacheSafety(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/large":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write(make([]byte, 2048))
+			return
+		case "/items":
+			// 创建资源后通过 Location 指向新资源，Content-Location 指向集合
+			w.Header().Set("Location", "/items/1")
+			w.Header().Set("Content-Location", "/list")
+			w.WriteHeader(http.StatusCreated)
+			return
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte("body " + r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+	cache := NewCache(NewMemoryCache(0)).SetMaxEntrySize(1024)
+
+	get := func(path, auth string) (string, string) {
+		t.Helper()
+		req := NewRequest().SetUrl(srv.URL + path).Get().SetCache(cache)
+		if auth != "" {
+			req.SetHeader("Authorization", auth)
+		}
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ReadResponseBody(resp)
+		return string(body), GetCacheStatus(resp)
+	}
+
+	// 带凭据且未声明 public 的响应不存储，另一个凭据的请求读不到
+	get("/private", "Bearer alice")
+	if body, status := get("/private", "Bearer bob"); body != "body Bearer bob" || status != CacheMiss {
+		t.Fatalf("credentialed response was shared: %q %s", body, status)
+	}
+	get("/public", "Bearer alice")
+	if _, status := get("/public", "Bearer bob"); status != CacheHit {
+		t.Fatalf("public response should be cached, got %s", status)
+	}
+
+	// 超过条目上限的响应不存储，也不影响读取
+	if body, _ := get("/large", ""); len(body) != 2048 {
+		t.Fatalf("large body truncated to %d", len(body))
+	}
+	if _, status := get("/large", ""); status != CacheMiss {
+		t.Fatalf("oversized response was cached: %s", status)
+	}
+
+	// HEAD 缓存保留响应头中的长度
+	for i, want := range []string{CacheMiss, CacheHit} {
+		resp, err := NewRequest().SetUrl(srv.URL + "/head").SetMethod(http.MethodHead).SetCache(cache).Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if GetCacheStatus(resp) != want || resp.ContentLength != int64(len("body ")) {
+			t.Fatalf("HEAD %d: status=%s ContentLength=%d", i, GetCacheStatus(resp), resp.ContentLength)
+		}
+	}
+
+	// 不安全方法同时使 Location/Content-Location 指向的资源失效
+	get("/items/1", "")
+	get("/list", "")
+	before := hits.Load()
+	if _, err := NewRequest().SetUrl(srv.URL + "/items").Post().SetCache(cache).DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	if _, status := get("/items/1", ""); status != CacheMiss {
+		t.Fatalf("Location target not invalidated: %s", status)
+	}
+	if _, status := get("/list", ""); status != CacheMiss {
+		t.Fatalf("Content-Location target not invalidated: %s", status)
+	}
+	if hits.Load() != before+3 {
+		t.Fatalf("unexpected server hits %d", hits.Load()-before)
+	}
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	m := NewMemoryCache(2)
+	m.Set("a", []byte("1"))
+	m.Set("b", []byte("2"))
+	m.Get("a")
+	m.Set("c", []byte("3"))
+	if _, ok := m.Get("b"); ok || m.Len() != 2 {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if _, ok := m.Get("a"); !ok {
+		t.Fatal("recently used entry was evicted")
+	}
+}
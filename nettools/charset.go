@@ -0,0 +1,129 @@
+package nettools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// 探测字符集时读取的字节数，与 HTML 规范的 meta 预扫描范围一致
+const charsetSniffLen = 1024
+
+// SetCharset 强制使用指定字符集（如 "gbk"、"big5"）解码响应，为空时自动探测
+func (r *Req) SetCharset(name string) *Req {
+	r.Charset = name
+	return r
+}
+
+// DoAndGetText 执行请求并返回转换为 UTF-8 的响应文本
+func (r *Req) DoAndGetText() (string, error) {
+	resp, err := r.Do()
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP错误状态码: %d", resp.StatusCode)
+	}
+
+	reader, _, err := NewUTF8Reader(resp.Body, resp.Header.Get("Content-Type"), r.Charset)
+	if err != nil {
+		return "", err
+	}
+	text, err := io.ReadAll(reader)
+	return string(text), err
+}
+
+// DetectCharset 依次根据 BOM、Content-Type、HTML meta 标签探测字符集，JSON 始终按 UTF-8 处理；
+// 无法确定时，含非 ASCII 字节且不是合法 UTF-8 的内容按 GB18030（兼容 GBK/GB2312）处理，其余按 UTF-8 处理
+func DetectCharset(content []byte, contentType string) (encoding.Encoding, string) {
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return unicode.UTF8, "utf-8"
+	}
+	e, name, certain := charset.DetermineEncoding(content, contentType)
+	if !certain && name == "windows-1252" {
+		if hasNonASCII(content) && !validUTF8Prefix(content) {
+			return simplifiedchinese.GB18030, "gb18030"
+		}
+		return unicode.UTF8, "utf-8"
+	}
+	return e, name
+}
+
+func hasNonASCII(content []byte) bool {
+	for _, b := range content {
+		if b >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// validUTF8Prefix 判断截取的内容是否为合法 UTF-8，末尾被截断的多字节字符不视为错误
+func validUTF8Prefix(content []byte) bool {
+	for i := len(content) - 1; i >= 0 && i >= len(content)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(content[i]) {
+			if !utf8.FullRune(content[i:]) {
+				content = content[:i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(content)
+}
+
+// NewUTF8Reader 返回转换为 UTF-8 的 Reader 以及使用的字符集名称，
+// charsetName 不为空时强制使用该字符集
+func NewUTF8Reader(r io.Reader, contentType, charsetName string) (io.Reader, string, error) {
+	var e encoding.Encoding
+	var name string
+	if charsetName != "" {
+		if e, name = charset.Lookup(charsetName); e == nil {
+			return nil, "", fmt.Errorf("不支持的字符集: %s", charsetName)
+		}
+	} else {
+		br := bufio.NewReaderSize(r, charsetSniffLen)
+		head, err := br.Peek(charsetSniffLen)
+		if err != nil && err != io.EOF {
+			return nil, "", fmt.Errorf("读取响应体失败: %w", err)
+		}
+		e, name = DetectCharset(head, contentType)
+		r = br
+	}
+	// BOMOverride 会去掉开头的 BOM，并在 BOM 与声明不一致时以 BOM 为准
+	return transform.NewReader(r, unicode.BOMOverride(e.NewDecoder())), name, nil
+}
+
+// ToUTF8 将内容转换为 UTF-8，charsetName 为空时自动探测
+func ToUTF8(content []byte, contentType, charsetName string) ([]byte, error) {
+	reader, _, err := NewUTF8Reader(bytes.NewReader(content), contentType, charsetName)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// ReadResponseBodyUTF8 读取响应体并转换为 UTF-8，读取后响应体仍可再次读取
+func ReadResponseBodyUTF8(resp *http.Response, charsetName ...string) ([]byte, error) {
+	body, err := ReadResponseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	var name string
+	if len(charsetName) > 0 {
+		name = charsetName[0]
+	}
+	return ToUTF8(body, resp.Header.Get("Content-Type"), name)
+}
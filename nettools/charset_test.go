@@ -0,0 +1,85 @@
+package nettools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+func TestCharsetDecoding(t *testing.T) {
+	const text = "中文网页内容"
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(text)
+	big5, _ := traditionalchinese.Big5.NewEncoder().String("中文網頁")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/header":
+			w.Header().Set("Content-Type", "text/html; charset=GBK")
+			w.Write([]byte(gbk))
+		case "/meta":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><meta charset="big5"></head><body>` + big5 + `</body></html>`))
+		case "/bom":
+			w.Header().Set("Content-Type", "text/plain; charset=gbk")
+			w.Write([]byte("\xef\xbb\xbf" + text))
+		case "/undeclared":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(gbk))
+		case "/ascii-prefix":
+			// 探测范围内只有 ASCII，UTF-8 中文出现在 1KiB 之后
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(strings.Repeat("a", 1500) + text))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"a":"` + strings.Repeat("a", 1500) + text + `"}`))
+		}
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		path, force, want string
+	}{
+		{"/header", "", text},
+		{"/meta", "", `<html><head><meta charset="big5"></head><body>中文網頁</body></html>`},
+		{"/bom", "", text},
+		{"/undeclared", "", text},
+		{"/undeclared", "gb2312", text},
+		{"/ascii-prefix", "", strings.Repeat("a", 1500) + text},
+		{"/json", "", `{"a":"` + strings.Repeat("a", 1500) + text + `"}`},
+	}
+	for _, c := range cases {
+		got, err := NewRequest().SetUrl(srv.URL + c.path).Get().SetCharset(c.force).DoAndGetText()
+		if err != nil || got != c.want {
+			t.Fatalf("%s: got %q err=%v", c.path, got, err)
+		}
+	}
+
+	resp, err := NewRequest().SetUrl(srv.URL + "/header").Get().Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ReadResponseBodyUTF8(resp)
+	if err != nil || string(body) != text {
+		t.Fatalf("ReadResponseBodyUTF8: %q %v", body, err)
+	}
+	if raw, _ := ReadResponseBody(resp); string(raw) != gbk {
+		t.Fatal("original body should remain readable")
+	}
+
+	if _, err := ToUTF8([]byte(gbk), "", "no-such-charset"); err == nil {
+		t.Fatal("expected unknown charset error")
+	}
+
+	// 探测范围截断在多字节字符中间时仍识别为 UTF-8
+	cut := []byte(strings.Repeat("a", charsetSniffLen-1) + text)[:charsetSniffLen]
+	if _, name := DetectCharset(cut, "text/plain"); name != "utf-8" {
+		t.Fatalf("truncated UTF-8 detected as %s", name)
+	}
+	if _, name := DetectCharset([]byte(gbk), "application/json; charset=gbk"); name != "utf-8" {
+		t.Fatalf("json detected as %s", name)
+	}
+}
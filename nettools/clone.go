@@ -0,0 +1,114 @@
+package nettools
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone 返回请求的深拷贝，常用于把配置好的 Req 作为模板，在各协程中复制后再修改。
+// Headers、Params、Data、Cookies、Files 以及 TLS、代理等配置均为独立副本；
+// Cookie 容器、连接池，以及限流器、熔断器、缓存、代理池、指标收集器保持共享。
+// Files 中的 io.Reader 无法复制，读取后不能再次上传
+func (r *Req) Clone() *Req {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 先组装模板的 Transport，使副本共用同一个连接池；失败时记录错误，副本在 Do 中返回
+	err := r.configureClient()
+
+	clone := &Req{
+		Ctx:     r.Ctx,
+		Method:  r.Method,
+		Url:     r.Url,
+		Params:  maps.Clone(r.Params),
+		Data:    maps.Clone(r.Data),
+		Headers: maps.Clone(r.Headers),
+		Verify:  r.Verify,
+		Proxy:   r.Proxy,
+		Timeout: r.Timeout,
+		Trace:   r.Trace,
+
+		CertPaths: slices.Clone(r.CertPaths),
+
+		Middlewares:    slices.Clone(r.Middlewares),
+		Metrics:        r.Metrics,
+		Route:          r.Route,
+		RateLimiter:    r.RateLimiter,
+		CircuitBreaker: r.CircuitBreaker,
+		Cache:          r.Cache,
+
+		TransportWrappers: slices.Clone(r.TransportWrappers),
+
+		DisableDecompression: r.DisableDecompression,
+		RequestEncoding:      r.RequestEncoding,
+		Charset:              r.Charset,
+		MaxBodySize:          r.MaxBodySize,
+
+		HTTPVersion: r.HTTPVersion,
+		UnixSocket:  r.UnixSocket,
+		DialContext: r.DialContext,
+		LocalAddr:   r.LocalAddr,
+		Interface:   r.Interface,
+		IPFamily:    r.IPFamily,
+
+		Resolver:      r.Resolver,
+		FallbackDelay: r.FallbackDelay,
+
+		ProxyChain:   slices.Clone(r.ProxyChain),
+		ProxyHeaders: maps.Clone(r.ProxyHeaders),
+		ProxyPool:    r.ProxyPool,
+		ProxyFromEnv: r.ProxyFromEnv,
+		NoProxy:      slices.Clone(r.NoProxy),
+
+		ClientCerts:   slices.Clone(r.ClientCerts),
+		CACertPEMs:    slices.Clone(r.CACertPEMs),
+		MinTLSVersion: r.MinTLSVersion,
+		MaxTLSVersion: r.MaxTLSVersion,
+		CipherSuites:  slices.Clone(r.CipherSuites),
+		ServerName:    r.ServerName,
+		PinnedSPKI:    slices.Clone(r.PinnedSPKI),
+		InsecureHosts: slices.Clone(r.InsecureHosts),
+
+		tlsErr:            r.tlsErr,
+		cloneErr:          err,
+		insecureTransport: r.insecureTransport,
+		transportStale:    r.transportStale,
+		appliedConfig:     r.appliedConfig,
+	}
+
+	for _, cookie := range r.Cookies {
+		c := *cookie
+		clone.Cookies = append(clone.Cookies, &c)
+	}
+	for _, file := range r.Files {
+		f := *file
+		clone.Files = append(clone.Files, &f)
+	}
+	if r.Redirect != nil {
+		policy := *r.Redirect
+		clone.Redirect = &policy
+	}
+	if r.ConnPool != nil {
+		pool := *r.ConnPool
+		clone.ConnPool = &pool
+	}
+	if r.WebSocket != nil {
+		ws := *r.WebSocket
+		ws.Subprotocols = slices.Clone(ws.Subprotocols)
+		clone.WebSocket = &ws
+	}
+
+	if r.Client != nil {
+		// CheckRedirect 指向模板的策略，副本的 Redirect 是独立副本，会在 Do 中替换；清除时恢复默认行为
+		client := *r.Client
+		clone.appliedRedirect = r.appliedRedirect
+		if layered, ok := client.Transport.(*layeredTransport); ok && layered.owner == r {
+			layered.shared.Store(true)
+			shared := &layeredTransport{owner: clone, base: layered.base, rt: layered.rt}
+			shared.shared.Store(true)
+			client.Transport = shared
+		}
+		clone.Client = &client
+	}
+	return clone
+}
@@ -0,0 +1,116 @@
+package nettools
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestCloneDeepCopy(t *testing.T) {
+	tmpl := NewRequest().SetUrl("http://example.com/").Get().
+		SetHeader("X-Tmpl", "1").
+		SetParams(map[string]interface{}{"q": "a"}).
+		AddCookie(&http.Cookie{Name: "sid", Value: "1"}).
+		SetMaxRedirects(3).
+		SetProxyHeader("X-Proxy", "1").
+		AddInsecureHost("example.com")
+
+	clone := tmpl.Clone()
+	clone.SetHeader("X-Tmpl", "2").SetHeader("X-Clone", "1")
+	clone.Params["q"] = "b"
+	clone.Cookies[0].Value = "2"
+	clone.SetMaxRedirects(5)
+	clone.ProxyHeaders["X-Proxy"] = "2"
+	clone.InsecureHosts[0] = "example.org"
+	clone.SetTimeout(0)
+
+	if tmpl.Headers["X-Tmpl"] != "1" || tmpl.Headers["X-Clone"] != "" {
+		t.Fatalf("headers shared: %v", tmpl.Headers)
+	}
+	if tmpl.Params["q"] != "a" {
+		t.Fatal("params shared")
+	}
+	if tmpl.Cookies[0].Value != "1" {
+		t.Fatal("cookies shared")
+	}
+	if tmpl.Redirect.MaxHops != 3 {
+		t.Fatal("redirect policy shared")
+	}
+	if tmpl.ProxyHeaders["X-Proxy"] != "1" || tmpl.InsecureHosts[0] != "example.com" {
+		t.Fatal("proxy/TLS config shared")
+	}
+	if tmpl.Client == clone.Client || tmpl.Client.Timeout == 0 {
+		t.Fatal("http.Client shared")
+	}
+	if tmpl.Client.Jar != clone.Client.Jar {
+		t.Fatal("cookie jar should be shared")
+	}
+	if tmpl.Client.Transport.(*layeredTransport).base != clone.Client.Transport.(*layeredTransport).base {
+		t.Fatal("connection pool should be shared")
+	}
+}
+
+func TestConcurrentDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "seen", Value: "1"})
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	req := NewRequest().SetUrl(srv.URL).Get().SetTrace(true).SetMaxRedirects(2).SetProxyFromEnv(false)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := req.DoAndGetBody()
+			if err != nil || string(body) != "ok" {
+				t.Errorf("unexpected result %q %v", body, err)
+			}
+			if req.GetRequest() == nil {
+				t.Error("missing last request")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentClones(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Id")))
+	}))
+
+	tmpl := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM)
+	base := tmpl.Clone().Client.Transport.(*layeredTransport).base.(*http.Transport)
+	config := base.TLSClientConfig
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := tmpl.Clone().SetHeader("X-Id", fmt.Sprint(i))
+			if i%5 == 0 {
+				// 修改 TLS 配置的副本使用独立的 Transport，不影响其他请求
+				if _, err := req.SetServerName("nettools.invalid").Do(); err == nil {
+					t.Error("expected name mismatch")
+				}
+				return
+			}
+			body, err := req.DoAndGetBody()
+			if err != nil || string(body) != fmt.Sprint(i) {
+				t.Errorf("clone %d: %q %v", i, body, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if base.TLSClientConfig != config || config.ServerName != "" {
+		t.Fatal("shared transport mutated by clone")
+	}
+	if _, err := tmpl.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,189 @@
+package nettools
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding 为自动解压时默认发送的 Accept-Encoding
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+// SetDecompression 设置是否自动协商并解压响应，默认开启；
+// 关闭后沿用 Transport 的默认行为（仅透明处理 gzip）
+func (r *Req) SetDecompression(enabled bool) *Req {
+	r.DisableDecompression = !enabled
+	return r
+}
+
+// SetRequestCompression 使用 gzip/deflate/br/zstd 压缩请求体，空字符串表示不压缩
+func (r *Req) SetRequestCompression(encoding string) *Req {
+	r.RequestEncoding = strings.ToLower(encoding)
+	return r
+}
+
+// DecompressResponse 按 Content-Encoding 替换为解压后的响应体，
+// 并移除 Content-Encoding/Content-Length；包含不支持的编码时保持原样
+func DecompressResponse(resp *http.Response) {
+	if resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	encodings := headerTokens(resp.Header, "Content-Encoding")
+	if len(encodings) == 0 {
+		return
+	}
+	for i, encoding := range encodings {
+		encoding = strings.ToLower(encoding)
+		switch encoding {
+		case "gzip", "x-gzip", "deflate", "br", "zstd", "identity":
+			encodings[i] = encoding
+		default:
+			return
+		}
+	}
+
+	resp.Body = &decodedBody{raw: resp.Body, encodings: encodings}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompressMiddleware 在未指定 Accept-Encoding 时声明支持的编码，并解压响应。
+// 显式设置 Accept-Encoding 后 Transport 不再自动处理 gzip，因此统一在这里解压
+func decompressMiddleware(next DoFunc) DoFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+			req.Header.Set("Accept-Encoding", AcceptEncoding)
+		}
+		resp, err := next(req)
+		if err != nil {
+			return nil, err
+		}
+		if req.Method != http.MethodHead {
+			DecompressResponse(resp)
+		}
+		return resp, nil
+	}
+}
+
+// decodedBody 在首次读取时创建解码器，以便空响应体直接返回 EOF
+type decodedBody struct {
+	raw       io.ReadCloser
+	encodings []string // 按编码顺序排列，解码时倒序处理
+	reader    io.Reader
+	closers   []func()
+	err       error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.err = b.init()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+func (b *decodedBody) init() error {
+	var reader io.Reader = b.raw
+	for i := len(b.encodings) - 1; i >= 0; i-- {
+		decoded, closer, err := newDecoder(b.encodings[i], reader)
+		if err != nil {
+			if err == io.EOF {
+				return io.EOF
+			}
+			return fmt.Errorf("解压响应体失败(%s): %w", b.encodings[i], err)
+		}
+		if closer != nil {
+			b.closers = append(b.closers, closer)
+		}
+		reader = decoded
+	}
+	b.reader = reader
+	return nil
+}
+
+func (b *decodedBody) Close() error {
+	for _, closer := range b.closers {
+		closer()
+	}
+	return b.raw.Close()
+}
+
+func newDecoder(encoding string, r io.Reader) (io.Reader, func(), error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, func() { gz.Close() }, nil
+	case "deflate":
+		// 标准 deflate 为 zlib 封装，部分服务端发送不带封装的原始 deflate 数据
+		br := bufio.NewReader(r)
+		header, err := br.Peek(2)
+		if err != nil {
+			return nil, nil, err
+		}
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, nil, err
+			}
+			return zr, func() { zr.Close() }, nil
+		}
+		fr := flate.NewReader(br)
+		return fr, func() { fr.Close() }, nil
+	case "br":
+		return brotli.NewReader(r), nil, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	default:
+		return r, nil, nil
+	}
+}
+
+// compressBody 将请求体压缩到内存中，保持请求体可重放
+func compressBody(body io.Reader, encoding string) (io.Reader, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("创建压缩器失败: %w", err)
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法: %s", encoding)
+	}
+
+	if _, err := io.Copy(w, body); err != nil {
+		return nil, fmt.Errorf("压缩请求体失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("压缩请求体失败: %w", err)
+	}
+	return &buf, nil
+}
@@ -0,0 +1,78 @@
+package nettools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecompression(t *testing.T) {
+	const text = "hello, compressed world"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := r.URL.Query().Get("enc")
+		if r.URL.Path == "/upload" {
+			// 解压请求体后原样返回
+			resp := &http.Response{Header: http.Header{"Content-Encoding": {r.Header.Get("Content-Encoding")}}, Body: r.Body}
+			DecompressResponse(resp)
+			io.Copy(w, resp.Body)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), enc) {
+			t.Errorf("Accept-Encoding %q does not offer %s", r.Header.Get("Accept-Encoding"), enc)
+		}
+		body, err := compressBody(strings.NewReader(text), enc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Encoding", enc)
+		io.Copy(w, body)
+	}))
+	defer srv.Close()
+
+	for _, enc := range []string{"gzip", "deflate", "br", "zstd"} {
+		body, err := NewRequest().SetUrl(srv.URL).Get().
+			SetParams(map[string]interface{}{"enc": enc}).DoAndGetBody()
+		if err != nil || string(body) != text {
+			t.Fatalf("%s: body=%q err=%v", enc, body, err)
+		}
+
+		body, err = NewRequest().SetUrl(srv.URL + "/upload").Post().
+			SetData(map[string]interface{}{"msg": text}).SetRequestCompression(enc).DoAndGetBody()
+		if err != nil || !strings.Contains(string(body), text) {
+			t.Fatalf("upload %s: body=%q err=%v", enc, body, err)
+		}
+	}
+
+	// 自定义 Accept-Encoding 时仍然解压
+	body, err := NewRequest().SetUrl(srv.URL).Get().SetHeader("Accept-Encoding", "gzip").
+		SetParams(map[string]interface{}{"enc": "gzip"}).DoAndGetBody()
+	if err != nil || string(body) != text {
+		t.Fatalf("custom Accept-Encoding: body=%q err=%v", body, err)
+	}
+}
+
+func TestReadResponseDecompress(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("plain text"))
+	gz.Close()
+
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Encoding": {"gzip"}},
+		Body:       io.NopCloser(&buf),
+	}
+	dump, err := ReadResponse(resp, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(dump), "\r\nplain text") || strings.Contains(string(dump), "Content-Encoding") {
+		t.Fatalf("unexpected dump %q", dump)
+	}
+}
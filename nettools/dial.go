@@ -0,0 +1,409 @@
+package nettools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// IPFamily 表示出站连接的地址族偏好
+type IPFamily int
+
+const (
+	IPAny      IPFamily = iota // 不限制；绑定网卡时优先 IPv4
+	IPv4Only                   // 只使用 IPv4
+	IPv6Only                   // 只使用 IPv6
+	PreferIPv4                 // 优先 IPv4，失败时尝试 IPv6
+	PreferIPv6                 // 优先 IPv6，失败时尝试 IPv4
+)
+
+func (f IPFamily) String() string {
+	switch f {
+	case IPAny:
+		return "any"
+	case IPv4Only:
+		return "ipv4-only"
+	case IPv6Only:
+		return "ipv6-only"
+	case PreferIPv4:
+		return "prefer-ipv4"
+	case PreferIPv6:
+		return "prefer-ipv6"
+	default:
+		return "unknown"
+	}
+}
+
+// DialFunc 表示建立连接的函数，签名与 http.Transport.DialContext 相同
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// SetUnixSocket 让所有请求通过 Unix 套接字发送，URL 中的主机名仅用于 Host 头，
+// 如 SetUnixSocket("/var/run/docker.sock").SetUrl("http://docker/version")
+func (r *Req) SetUnixSocket(path string) *Req {
+	r.UnixSocket = path
+	r.transportStale = true
+	return r
+}
+
+// SetAbstractSocket 让所有请求通过 Linux 抽象套接字发送，name 不含开头的 "@"
+func (r *Req) SetAbstractSocket(name string) *Req {
+	return r.SetUnixSocket("@" + name)
+}
+
+// SetDialContext 使用自定义函数建立连接，优先于源地址与网卡绑定
+func (r *Req) SetDialContext(dial DialFunc) *Req {
+	r.DialContext = dial
+	r.transportStale = true
+	return r
+}
+
+// SetLocalAddr 将出站连接绑定到源 IP，用于多出口主机
+func (r *Req) SetLocalAddr(ip string) *Req {
+	r.LocalAddr = ip
+	r.transportStale = true
+	return r
+}
+
+// SetInterface 将出站连接绑定到网卡，源地址在建立连接时通过 GetIfaceIpv4Global/GetIfaceIpv6Global 选取，
+// 网卡没有全局地址（如回环网卡）时使用网卡上的其他地址；family 可选，用于指定地址族偏好
+func (r *Req) SetInterface(name string, family ...IPFamily) *Req {
+	r.Interface = name
+	if len(family) > 0 {
+		r.IPFamily = family[0]
+	}
+	r.transportStale = true
+	return r
+}
+
+// SetIPFamily 设置出站连接的地址族偏好
+func (r *Req) SetIPFamily(family IPFamily) *Req {
+	r.IPFamily = family
+	r.transportStale = true
+	return r
+}
+
+// SetHappyEyeballs 设置 Happy Eyeballs（RFC 8305）的等待时间：首选地址族在 delay 内未连通时，
+// 并行尝试另一地址族，delay 为 0 时使用 300ms，小于 0 时按顺序依次尝试
+func (r *Req) SetHappyEyeballs(delay time.Duration) *Req {
+	r.FallbackDelay = delay
+	r.transportStale = true
+	return r
+}
+
+// reqDialer 按 Req 的拨号选项建立连接，同时实现 Dial 与 DialContext，可作为代理链的直连拨号器
+type reqDialer struct {
+	dialer     net.Dialer
+	unixSocket string
+	custom     DialFunc
+	local      net.IP // 显式指定的源地址
+	iface      string
+	family     IPFamily
+	resolver   *Resolver
+	delay      time.Duration // 启动备用地址族前的等待时间
+}
+
+// dialGroup 表示同一地址族下依次尝试的地址
+type dialGroup struct {
+	network string
+	addrs   []string
+}
+
+// newDialer 根据拨号选项创建拨号器，installed 表示需要替换 Transport 默认的拨号方式
+func (r *Req) newDialer() (dialer *reqDialer, installed bool, err error) {
+	dialer = &reqDialer{
+		dialer:     net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		unixSocket: r.UnixSocket,
+		custom:     r.DialContext,
+		iface:      r.Interface,
+		family:     r.IPFamily,
+		resolver:   r.Resolver,
+		delay:      r.FallbackDelay,
+	}
+	dialer.dialer.FallbackDelay = r.FallbackDelay
+	if r.ConnPool != nil && r.ConnPool.KeepAlive != 0 {
+		dialer.dialer.KeepAlive = r.ConnPool.KeepAlive
+		installed = true
+	}
+	if r.LocalAddr != "" {
+		if dialer.local = net.ParseIP(r.LocalAddr); dialer.local == nil {
+			return nil, false, fmt.Errorf("无效的源地址: %s", r.LocalAddr)
+		}
+		if (r.IPFamily == IPv4Only && dialer.local.To4() == nil) || (r.IPFamily == IPv6Only && dialer.local.To4() != nil) {
+			return nil, false, fmt.Errorf("源地址 %s 与地址族 %s 不匹配", r.LocalAddr, r.IPFamily)
+		}
+		installed = true
+	}
+	if r.UnixSocket != "" || r.DialContext != nil || r.Interface != "" || r.IPFamily != IPAny ||
+		r.Resolver != nil || r.FallbackDelay != 0 {
+		installed = true
+	}
+	return dialer, installed, nil
+}
+
+func (d *reqDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *reqDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch {
+	case d.unixSocket != "":
+		unix := net.Dialer{Timeout: d.dialer.Timeout}
+		return unix.DialContext(ctx, "unix", d.unixSocket)
+	case d.custom != nil:
+		return d.custom(ctx, network, addr)
+	case d.local == nil && d.iface == "" && d.family == IPAny && d.resolver == nil:
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	target := net.ParseIP(host)
+	networks, err := d.networks(network, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []dialGroup
+	if d.resolver != nil && target == nil {
+		ips, err := d.resolver.LookupIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if groups, err = groupAddrs(ips, port, networks); err != nil {
+			return nil, fmt.Errorf("%s: %w", host, err)
+		}
+	} else {
+		for _, tcp := range networks {
+			groups = append(groups, dialGroup{network: tcp, addrs: []string{addr}})
+		}
+	}
+	return d.dialParallel(ctx, groups)
+}
+
+// groupAddrs 将解析结果按 networks 的顺序分组；未指定偏好时以第一个地址的地址族为首选
+func groupAddrs(ips []net.IP, port string, networks []string) ([]dialGroup, error) {
+	if len(networks) == 1 && networks[0] == "tcp" && len(ips) > 0 {
+		networks = []string{"tcp6", "tcp4"}
+		if ips[0].To4() != nil {
+			networks = []string{"tcp4", "tcp6"}
+		}
+	}
+	var groups []dialGroup
+	for _, network := range networks {
+		group := dialGroup{network: network}
+		for _, ip := range ips {
+			if (ip.To4() == nil) == (network == "tcp6") || (network != "tcp4" && network != "tcp6") {
+				group.addrs = append(group.addrs, net.JoinHostPort(ip.String(), port))
+			}
+		}
+		if len(group.addrs) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("没有可用于 %s 的地址", strings.Join(networks, "/"))
+	}
+	return groups, nil
+}
+
+// dialParallel 先连接首选地址族，delay 后仍未连通（或首选地址族失败）时启动下一组，返回最先建立的连接
+func (d *reqDialer) dialParallel(ctx context.Context, groups []dialGroup) (net.Conn, error) {
+	if len(groups) == 1 {
+		return d.dialSerial(ctx, groups[0])
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(groups))
+	started, pending := 0, 0
+	start := func() {
+		group := groups[started]
+		started++
+		pending++
+		go func() {
+			conn, err := d.dialSerial(ctx, group)
+			results <- result{conn, err}
+		}()
+	}
+	start()
+
+	var fallback <-chan time.Time
+	if d.delay >= 0 {
+		delay := d.delay
+		if delay == 0 {
+			delay = 300 * time.Millisecond
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		fallback = timer.C
+	}
+
+	var errs []error
+	for {
+		select {
+		case <-fallback:
+			if started < len(groups) {
+				start()
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// 关闭其他地址族迟到的连接
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			errs = append(errs, res.err)
+			if started < len(groups) && ctx.Err() == nil {
+				start()
+			} else if pending == 0 {
+				return nil, errors.Join(errs...)
+			}
+		}
+	}
+}
+
+// dialSerial 依次连接同一地址族的地址，该地址族使用对应的源地址
+func (d *reqDialer) dialSerial(ctx context.Context, group dialGroup) (net.Conn, error) {
+	var errs []error
+	for _, addr := range group.addrs {
+		host, _, _ := net.SplitHostPort(addr)
+		dialer := d.dialer
+		local, err := d.localAddr(group.network, net.ParseIP(host))
+		if err != nil {
+			return nil, err
+		}
+		dialer.LocalAddr = local
+		conn, err := dialer.DialContext(ctx, group.network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// networks 按地址族偏好返回依次尝试的网络类型
+func (d *reqDialer) networks(network string, target net.IP) ([]string, error) {
+	if network != "tcp" {
+		return []string{network}, nil
+	}
+
+	if target != nil {
+		network = "tcp6"
+		if target.To4() != nil {
+			network = "tcp4"
+		}
+		if (d.family == IPv4Only && network == "tcp6") || (d.family == IPv6Only && network == "tcp4") {
+			return nil, fmt.Errorf("目标地址 %s 与地址族 %s 不匹配", target, d.family)
+		}
+		return []string{network}, nil
+	}
+
+	// 显式源地址决定了地址族
+	if d.local != nil {
+		if d.local.To4() != nil {
+			return []string{"tcp4"}, nil
+		}
+		return []string{"tcp6"}, nil
+	}
+
+	switch d.family {
+	case IPv4Only:
+		return []string{"tcp4"}, nil
+	case IPv6Only:
+		return []string{"tcp6"}, nil
+	case PreferIPv6:
+		return []string{"tcp6", "tcp4"}, nil
+	case PreferIPv4:
+		return []string{"tcp4", "tcp6"}, nil
+	}
+	// 未指定偏好时，绑定网卡需要按地址族分别选取源地址
+	if d.iface != "" {
+		return []string{"tcp4", "tcp6"}, nil
+	}
+	return []string{"tcp"}, nil
+}
+
+// localAddr 返回指定网络类型使用的源地址，未绑定时返回 nil
+func (d *reqDialer) localAddr(network string, target net.IP) (net.Addr, error) {
+	v6 := network == "tcp6"
+	if d.local != nil {
+		if (d.local.To4() == nil) != v6 {
+			return nil, fmt.Errorf("源地址 %s 无法用于 %s 连接", d.local, network)
+		}
+		return &net.TCPAddr{IP: d.local}, nil
+	}
+	if d.iface == "" {
+		return nil, nil
+	}
+	addr, err := interfaceAddr(d.iface, v6, target)
+	if err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+// interfaceAddr 优先使用网卡上的全局单播地址；没有全局地址（如回环网卡）
+// 或目标为回环、链路本地地址时，使用网卡上同族的其他地址
+func interfaceAddr(iface string, v6 bool, target net.IP) (*net.TCPAddr, error) {
+	lookup, family := GetIfaceIpv4Global, "IPv4"
+	if v6 {
+		lookup, family = GetIfaceIpv6Global, "IPv6"
+	}
+	info, err := lookup(iface)
+	if err != nil {
+		return nil, fmt.Errorf("获取网卡 %s 信息失败: %w", iface, err)
+	}
+	if !info.IfaceIsUp {
+		return nil, fmt.Errorf("网卡 %s 未启用", iface)
+	}
+	local := target != nil && (target.IsLoopback() || target.IsLinkLocalUnicast())
+	if len(info.IfaceIpNets) > 0 && !local {
+		return &net.TCPAddr{IP: info.IfaceIpNets[0].IP}, nil
+	}
+
+	all, err := GetInterFaceInfo(iface)
+	if err != nil {
+		return nil, fmt.Errorf("获取网卡 %s 信息失败: %w", iface, err)
+	}
+	if addr := pickLocalAddr(all.IfaceIpNets, v6, target, iface); addr != nil {
+		return addr, nil
+	}
+	return nil, fmt.Errorf("网卡 %s 上没有可用的 %s 地址", iface, family)
+}
+
+// pickLocalAddr 选取指定地址族的地址，链路本地的 IPv6 地址只用于连接链路本地目标
+func pickLocalAddr(ipNets []net.IPNet, v6 bool, target net.IP, iface string) *net.TCPAddr {
+	for _, ipNet := range ipNets {
+		ip := ipNet.IP
+		if (ip.To4() == nil) != v6 {
+			continue
+		}
+		if v6 && ip.IsLinkLocalUnicast() {
+			if target != nil && target.IsLinkLocalUnicast() {
+				return &net.TCPAddr{IP: ip, Zone: iface}
+			}
+			continue
+		}
+		return &net.TCPAddr{IP: ip}
+	}
+	return nil
+}
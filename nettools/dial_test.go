@@ -0,0 +1,207 @@
+package nettools
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newUnixServer(t *testing.T, path string) {
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix socket unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.Path)
+	}))
+	srv.Listener.Close()
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nettools.sock")
+	newUnixServer(t, path)
+
+	body, err := NewRequest().SetUnixSocket(path).SetUrl("http://docker/version").Get().DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "docker /version" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestAbstractSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract sockets are Linux only")
+	}
+	name := fmt.Sprintf("nettools-test-%d", os.Getpid())
+	newUnixServer(t, "@"+name)
+
+	body, err := NewRequest().SetAbstractSocket(name).SetUrl("http://local/ping").Get().DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "local /ping" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestCustomDialContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+
+	var dials atomic.Int32
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		var d net.Dialer
+		return d.DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	body, err := NewRequest().SetDialContext(dial).SetUrl("http://service.internal/").Get().DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "service.internal" || dials.Load() != 1 {
+		t.Fatalf("unexpected body %q, dials %d", body, dials.Load())
+	}
+}
+
+func TestLocalAddr(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		w.Write([]byte(host))
+	}))
+	defer srv.Close()
+
+	// Linux 上整个 127.0.0.0/8 都指向回环网卡
+	if runtime.GOOS == "linux" {
+		body, err := NewRequest().SetLocalAddr("127.0.0.2").SetUrl(srv.URL).Get().DoAndGetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "127.0.0.2" {
+			t.Fatalf("unexpected source address %q", body)
+		}
+	}
+
+	if _, err := NewRequest().SetLocalAddr("not-an-ip").SetUrl(srv.URL).Get().Do(); err == nil {
+		t.Fatal("expected invalid source address error")
+	}
+}
+
+func TestInterface(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	loopback := ""
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("no loopback interface")
+	}
+
+	if _, err := NewRequest().SetInterface(loopback).SetUrl(srv.URL).Get().DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	if _, err := NewRequest().SetInterface(loopback, IPv4Only).SetUrl("http://localhost:" + port).Get().DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewRequest().SetInterface("nettools-missing0").SetUrl(srv.URL).Get().Do()
+	if err == nil || !strings.Contains(err.Error(), "nettools-missing0") {
+		t.Fatalf("expected missing interface error, got %v", err)
+	}
+}
+
+func TestIPFamily(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	localhost := "http://localhost:" + port + "/"
+
+	// 服务只监听 IPv4，优先 IPv6 时回落到 IPv4
+	if _, err := NewRequest().SetIPFamily(PreferIPv6).SetUrl(localhost).Get().DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRequest().SetIPFamily(IPv4Only).SetUrl(localhost).Get().DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRequest().SetIPFamily(IPv6Only).SetUrl(localhost).Get().Do(); err == nil {
+		t.Fatal("expected IPv6-only dial to fail against an IPv4 listener")
+	}
+	if _, err := NewRequest().SetIPFamily(IPv6Only).SetUrl(srv.URL).Get().Do(); err == nil {
+		t.Fatal("expected family mismatch for IPv4 target")
+	}
+	if _, err := NewRequest().SetIPFamily(IPv6Only).SetLocalAddr("127.0.0.1").SetUrl(srv.URL).Get().Do(); err == nil {
+		t.Fatal("expected family mismatch for IPv4 source address")
+	}
+}
+
+func TestDialerNetworks(t *testing.T) {
+	cases := []struct {
+		dialer *reqDialer
+		target string
+		want   string
+	}{
+		{&reqDialer{family: PreferIPv6}, "", "tcp6,tcp4"},
+		{&reqDialer{family: PreferIPv4}, "", "tcp4,tcp6"},
+		{&reqDialer{family: IPv6Only}, "", "tcp6"},
+		{&reqDialer{family: PreferIPv6}, "192.0.2.1", "tcp4"},
+		{&reqDialer{iface: "eth0"}, "", "tcp4,tcp6"},
+		{&reqDialer{local: net.ParseIP("2001:db8::1"), family: PreferIPv4}, "", "tcp6"},
+		{&reqDialer{}, "", "tcp"},
+	}
+	for _, c := range cases {
+		networks, err := c.dialer.networks("tcp", net.ParseIP(c.target))
+		if err != nil || strings.Join(networks, ",") != c.want {
+			t.Errorf("%+v %q: got %v %v, want %s", c.dialer, c.target, networks, err, c.want)
+		}
+	}
+	if _, err := (&reqDialer{family: IPv4Only}).networks("tcp", net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("expected mismatch error")
+	}
+}
+
+func TestPickLocalAddr(t *testing.T) {
+	ipNets := []net.IPNet{
+		{IP: net.ParseIP("fe80::1")},
+		{IP: net.ParseIP("2001:db8::1")},
+		{IP: net.ParseIP("192.0.2.1").To4()},
+	}
+	if addr := pickLocalAddr(ipNets, false, nil, "eth0"); addr.IP.String() != "192.0.2.1" {
+		t.Fatalf("IPv4: %v", addr)
+	}
+	if addr := pickLocalAddr(ipNets, true, nil, "eth0"); addr.IP.String() != "2001:db8::1" {
+		t.Fatalf("IPv6: %v", addr)
+	}
+	if addr := pickLocalAddr(ipNets, true, net.ParseIP("fe80::2"), "eth0"); addr.IP.String() != "fe80::1" || addr.Zone != "eth0" {
+		t.Fatalf("link-local target: %v", addr)
+	}
+	if addr := pickLocalAddr(ipNets[:2], false, nil, "eth0"); addr != nil {
+		t.Fatalf("expected no IPv4 address, got %v", addr)
+	}
+}
@@ -0,0 +1,466 @@
+package nettools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	defaultDNSTimeout = 5 * time.Second
+	defaultDNSMaxTTL  = 10 * time.Minute
+	dnsUDPSize        = 1232 // EDNS0 通告的 UDP 报文大小，避免 IP 分片
+)
+
+// ErrNoSuchHost 表示域名不存在或没有可用的地址
+var ErrNoSuchHost = errors.New("域名不存在")
+
+// Resolver 表示自定义 DNS 解析器：静态主机映射、指定的 DNS 服务器（UDP/TCP/DoH）以及按 TTL 缓存，
+// 可在多个请求间共享。未指定服务器时使用系统解析器，系统解析结果不缓存
+type Resolver struct {
+	Timeout      time.Duration // 单次查询超时，<=0 时为 5s
+	MinTTL       time.Duration // 缓存时长下限
+	MaxTTL       time.Duration // 缓存时长上限，<=0 时为 10 分钟
+	DisableCache bool
+	HTTPClient   *http.Client // DoH 使用的客户端，为空时使用 http.DefaultClient
+
+	mu      sync.Mutex
+	hosts   map[string][]net.IP
+	servers []*url.URL
+	cache   map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// NewResolver 创建解析器，servers 支持 "8.8.8.8"、"udp://8.8.8.8:53"、"tcp://1.1.1.1"
+// 以及 DoH 地址 "https://dns.google/dns-query"，按顺序尝试
+func NewResolver(servers ...string) (*Resolver, error) {
+	r := &Resolver{
+		hosts: make(map[string][]net.IP),
+		cache: make(map[string]dnsCacheEntry),
+	}
+	for _, raw := range servers {
+		server, err := parseDNSServer(raw)
+		if err != nil {
+			return nil, err
+		}
+		r.servers = append(r.servers, server)
+	}
+	return r, nil
+}
+
+func parseDNSServer(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "udp://" + raw
+	}
+	server, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("解析DNS服务器地址失败: %w", err)
+	}
+	switch server.Scheme {
+	case "udp", "tcp":
+		if server.Port() == "" {
+			server.Host = net.JoinHostPort(server.Hostname(), "53")
+		}
+	case "https", "http":
+	default:
+		return nil, fmt.Errorf("不支持的DNS服务器协议: %s", server.Scheme)
+	}
+	return server, nil
+}
+
+// SetResolver 使用自定义 DNS 解析器，同一个解析器可在多个请求间共享缓存与静态映射
+func (r *Req) SetResolver(resolver *Resolver) *Req {
+	r.Resolver = resolver
+	r.transportStale = true
+	return r
+}
+
+// AddHost 添加静态映射，类似 /etc/hosts，优先于 DNS 查询；至少需要一个地址
+func (r *Resolver) AddHost(host string, ips ...string) error {
+	if len(ips) == 0 {
+		return fmt.Errorf("静态映射 %s 没有指定IP地址", host)
+	}
+	parsed := make([]net.IP, 0, len(ips))
+	for _, raw := range ips {
+		ip := net.ParseIP(raw)
+		if ip == nil {
+			return fmt.Errorf("无效的IP地址: %s", raw)
+		}
+		parsed = append(parsed, ip)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hosts[normalizeHost(host)] = parsed
+	return nil
+}
+
+// ClearCache 清空解析缓存
+func (r *Resolver) ClearCache() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.cache)
+}
+
+// LookupIP 解析域名，IPv6 地址排在 IPv4 之前（RFC 8305），静态映射保持添加时的顺序
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	name := normalizeHost(host)
+
+	r.mu.Lock()
+	if ips, ok := r.hosts[name]; ok {
+		r.mu.Unlock()
+		return append([]net.IP(nil), ips...), nil
+	}
+	if entry, ok := r.cache[name]; ok {
+		if time.Now().Before(entry.expires) {
+			r.mu.Unlock()
+			return append([]net.IP(nil), entry.ips...), nil
+		}
+		delete(r.cache, name)
+	}
+	servers := r.servers
+	r.mu.Unlock()
+
+	if len(servers) == 0 {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ips := make([]net.IP, 0, len(addrs))
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+		return ips, nil
+	}
+
+	var errs []error
+	for _, server := range servers {
+		ips, ttl, err := r.query(ctx, server, name)
+		if err == nil {
+			r.store(name, ips, ttl)
+			return ips, nil
+		}
+		// 域名不存在时无需再询问其他服务器
+		if errors.Is(err, ErrNoSuchHost) || ctx.Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (r *Resolver) store(name string, ips []net.IP, ttl time.Duration) {
+	if r.DisableCache {
+		return
+	}
+	maxTTL := r.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultDNSMaxTTL
+	}
+	ttl = min(max(ttl, r.MinTTL), maxTTL)
+	if ttl <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache[name] = dnsCacheEntry{ips: ips, expires: time.Now().Add(ttl)}
+}
+
+// query 同时查询 A 与 AAAA 记录，ttl 为所有记录中最小的 TTL
+func (r *Resolver) query(ctx context.Context, server *url.URL, name string) ([]net.IP, time.Duration, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultDNSTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type answer struct {
+		ips []net.IP
+		ttl time.Duration
+		err error
+	}
+	types := []dnsmessage.Type{dnsmessage.TypeAAAA, dnsmessage.TypeA}
+	answers := make([]answer, len(types))
+	var wg sync.WaitGroup
+	for i, qtype := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ips, ttl, err := r.exchange(ctx, server, name, qtype)
+			answers[i] = answer{ips: ips, ttl: ttl, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var ips []net.IP
+	ttl := time.Duration(-1)
+	var errs []error
+	for _, a := range answers {
+		if a.err != nil {
+			errs = append(errs, a.err)
+			continue
+		}
+		ips = append(ips, a.ips...)
+		if len(a.ips) > 0 && (ttl < 0 || a.ttl < ttl) {
+			ttl = a.ttl
+		}
+	}
+	if len(ips) > 0 {
+		return ips, ttl, nil
+	}
+	if len(errs) > 0 {
+		return nil, 0, errors.Join(errs...)
+	}
+	return nil, 0, fmt.Errorf("%w: %s 没有 A/AAAA 记录", ErrNoSuchHost, name)
+}
+
+// exchange 向服务器发送一次查询，UDP 响应被截断时改用 TCP 重试
+func (r *Resolver) exchange(ctx context.Context, server *url.URL, name string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	fqdn, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return nil, 0, fmt.Errorf("无效的域名 %s: %w", name, err)
+	}
+	// DoH 请求的 ID 固定为 0，便于 HTTP 缓存（RFC 8484 4.1）；UDP/TCP 使用不可预测的 ID 防止伪造响应
+	var id uint16
+	if server.Scheme == "udp" || server.Scheme == "tcp" {
+		var b [2]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, 0, fmt.Errorf("生成DNS查询ID失败: %w", err)
+		}
+		id = binary.BigEndian.Uint16(b[:])
+	}
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: fqdn, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(dnsUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, 0, err
+	}
+	msg.Additionals = []dnsmessage.Resource{{Header: opt, Body: &dnsmessage.OPTResource{}}}
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("构造DNS查询失败: %w", err)
+	}
+
+	var resp []byte
+	switch server.Scheme {
+	case "udp":
+		resp, err = exchangeUDP(ctx, server.Host, query, id)
+		if err == nil && truncated(resp) {
+			resp, err = exchangeTCP(ctx, server.Host, query)
+		}
+	case "tcp":
+		resp, err = exchangeTCP(ctx, server.Host, query)
+	default:
+		resp, err = r.exchangeHTTPS(ctx, server, query)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("DNS查询 %s 失败: %w", server.Redacted(), err)
+	}
+	return parseDNSAnswer(resp, id, name, qtype)
+}
+
+func exchangeUDP(ctx context.Context, addr string, query []byte, id uint16) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, dnsUDPSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// 丢弃 ID 不匹配的报文（迟到或伪造的响应）
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+func exchangeTCP(ctx context.Context, addr string, query []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(framed, query...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Resolver) exchangeHTTPS(ctx context.Context, server *url.URL, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.String(), bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP错误状态码: %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+}
+
+func truncated(resp []byte) bool {
+	var p dnsmessage.Parser
+	header, err := p.Start(resp)
+	return err == nil && header.Truncated
+}
+
+// parseDNSAnswer 提取应答中的 A/AAAA 记录，CNAME 链由递归服务器展开。
+// 响应的问题必须与查询一致，只接受属于查询域名或其 CNAME 别名的记录
+func parseDNSAnswer(resp []byte, id uint16, name string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(resp)
+	if err != nil {
+		return nil, 0, fmt.Errorf("解析DNS响应失败: %w", err)
+	}
+	if header.ID != id || !header.Response {
+		return nil, 0, fmt.Errorf("DNS响应与查询不匹配")
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, fmt.Errorf("%w: %s", ErrNoSuchHost, name)
+	default:
+		return nil, 0, fmt.Errorf("DNS服务器返回错误: %s", header.RCode)
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil, 0, fmt.Errorf("解析DNS响应失败: %w", err)
+	}
+	if len(questions) != 1 || dnsName(questions[0].Name) != name ||
+		questions[0].Type != qtype || questions[0].Class != dnsmessage.ClassINET {
+		return nil, 0, fmt.Errorf("DNS响应与查询不匹配")
+	}
+
+	type record struct {
+		owner  string
+		target string // CNAME 指向的域名
+		ip     net.IP
+		ttl    uint32
+	}
+	var records []record
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("解析DNS响应失败: %w", err)
+		}
+		rec := record{owner: dnsName(h.Name), ttl: h.TTL}
+		switch {
+		case h.Type == dnsmessage.TypeCNAME:
+			cname, err := p.CNAMEResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("解析DNS响应失败: %w", err)
+			}
+			rec.target = dnsName(cname.CNAME)
+		case h.Type == dnsmessage.TypeA && qtype == dnsmessage.TypeA:
+			a, err := p.AResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("解析DNS响应失败: %w", err)
+			}
+			rec.ip = net.IP(a.A[:])
+		case h.Type == dnsmessage.TypeAAAA && qtype == dnsmessage.TypeAAAA:
+			aaaa, err := p.AAAAResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("解析DNS响应失败: %w", err)
+			}
+			rec.ip = net.IP(aaaa.AAAA[:])
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, fmt.Errorf("解析DNS响应失败: %w", err)
+			}
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	// 从查询域名出发沿 CNAME 展开别名，CNAME 记录不一定按链的顺序排列
+	owners := map[string]bool{name: true}
+	for changed := true; changed; {
+		changed = false
+		for _, rec := range records {
+			if rec.target != "" && owners[rec.owner] && !owners[rec.target] {
+				owners[rec.target] = true
+				changed = true
+			}
+		}
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	for _, rec := range records {
+		if rec.ip == nil || !owners[rec.owner] {
+			continue
+		}
+		if len(ips) == 0 || rec.ttl < ttl {
+			ttl = rec.ttl
+		}
+		ips = append(ips, rec.ip)
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+// dnsName 返回去掉结尾 "." 的小写域名，与 normalizeHost 的结果一致
+func dnsName(name dnsmessage.Name) string {
+	return normalizeHost(name.String())
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
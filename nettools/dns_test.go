@@ -0,0 +1,346 @@
+package nettools
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsStandIn 是测试用的 DNS 服务器，同时提供 UDP、TCP 与 DoH 三种接入方式
+type dnsStandIn struct {
+	records  map[string][]net.IP // 带结尾 "." 的域名 -> 地址
+	ttl      atomic.Uint32
+	truncate atomic.Bool // UDP 响应只返回 TC 标志
+	queries  atomic.Int32
+
+	udp, tcp string
+	doh      string
+}
+
+func newDNSStandIn(t *testing.T, records map[string][]net.IP) *dnsStandIn {
+	s := &dnsStandIn{records: records}
+	s.ttl.Store(300)
+
+	// UDP 与 TCP 监听同一端口，便于截断后改用 TCP 重试
+	var pc net.PacketConn
+	var ln net.Listener
+	for i := 0; pc == nil; i++ {
+		var err error
+		if ln, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if pc, err = net.ListenPacket("udp", ln.Addr().String()); err != nil {
+			ln.Close()
+			if i == 10 {
+				t.Fatal(err)
+			}
+		}
+	}
+	t.Cleanup(func() { pc.Close(); ln.Close() })
+	s.udp = pc.LocalAddr().String()
+	s.tcp = ln.Addr().String()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(s.answer(buf[:n], s.truncate.Load()), addr)
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				resp := s.answer(query, false)
+				conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+			}()
+		}
+	}()
+
+	doh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(s.answer(query, false))
+	}))
+	t.Cleanup(doh.Close)
+	s.doh = doh.URL + "/dns-query"
+	return s
+}
+
+func (s *dnsStandIn) answer(query []byte, truncate bool) []byte {
+	s.queries.Add(1)
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+	ips, ok := s.records[q.Name.String()]
+	respHeader := dnsmessage.Header{ID: header.ID, Response: true, RecursionAvailable: true, Truncated: truncate}
+	if !ok {
+		respHeader.RCode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, respHeader)
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: s.ttl.Load()}
+	for _, ip := range ips {
+		if truncate {
+			break
+		}
+		if v4 := ip.To4(); v4 != nil && q.Type == dnsmessage.TypeA {
+			b.AResource(rh, dnsmessage.AResource{A: [4]byte(v4)})
+		} else if v4 == nil && q.Type == dnsmessage.TypeAAAA {
+			b.AAAAResource(rh, dnsmessage.AAAAResource{AAAA: [16]byte(ip.To16())})
+		}
+	}
+	resp, _ := b.Finish()
+	return resp
+}
+
+func newHostEchoServer(t *testing.T) (port string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	t.Cleanup(srv.Close)
+	_, port, _ = net.SplitHostPort(srv.Listener.Addr().String())
+	return port
+}
+
+func TestResolverHosts(t *testing.T) {
+	port := newHostEchoServer(t)
+	resolver, err := NewResolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resolver.AddHost("Staging.Example.com", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolver.AddHost("bad.example.com", "not-an-ip"); err == nil {
+		t.Fatal("expected invalid address error")
+	}
+	if err := resolver.AddHost("empty.example.com"); err == nil {
+		t.Fatal("expected error for mapping without addresses")
+	}
+
+	url := "http://staging.example.com:" + port + "/"
+	body, err := NewRequest().SetResolver(resolver).SetUrl(url).Get().DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "staging.example.com:"+port {
+		t.Fatalf("unexpected host %q", body)
+	}
+}
+
+func TestParseDNSAnswer(t *testing.T) {
+	type answer struct {
+		owner, cname string
+		ip           net.IP
+	}
+	build := func(question string, qtype dnsmessage.Type, answers ...answer) []byte {
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 7, Response: true})
+		b.StartQuestions()
+		b.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(question), Type: qtype, Class: dnsmessage.ClassINET})
+		b.StartAnswers()
+		for _, a := range answers {
+			rh := dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(a.owner), Class: dnsmessage.ClassINET, TTL: 60}
+			if a.cname != "" {
+				b.CNAMEResource(rh, dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(a.cname)})
+			} else {
+				b.AResource(rh, dnsmessage.AResource{A: [4]byte(a.ip.To4())})
+			}
+		}
+		resp, _ := b.Finish()
+		return resp
+	}
+	ip := net.ParseIP("192.0.2.1")
+
+	// 问题与查询不一致的响应直接拒绝
+	for _, resp := range [][]byte{
+		build("other.test.", dnsmessage.TypeA, answer{owner: "other.test.", ip: ip}),
+		build("www.test.", dnsmessage.TypeAAAA),
+	} {
+		if _, _, err := parseDNSAnswer(resp, 7, "www.test", dnsmessage.TypeA); err == nil {
+			t.Fatal("expected mismatched question to be rejected")
+		}
+	}
+
+	// 不属于查询域名及其别名的记录被忽略；CNAME 乱序时同样能展开，域名不区分大小写
+	resp := build("WWW.test.", dnsmessage.TypeA,
+		answer{owner: "evil.test.", ip: net.ParseIP("203.0.113.1")},
+		answer{owner: "cdn.test.", ip: ip},
+		answer{owner: "edge.test.", cname: "cdn.test."},
+		answer{owner: "www.test.", cname: "edge.test."},
+	)
+	ips, _, err := parseDNSAnswer(resp, 7, "www.test", dnsmessage.TypeA)
+	if err != nil || len(ips) != 1 || !ips[0].Equal(ip) {
+		t.Fatalf("unexpected answer %v %v", ips, err)
+	}
+}
+
+func TestResolverServers(t *testing.T) {
+	port := newHostEchoServer(t)
+	dns := newDNSStandIn(t, map[string][]net.IP{"api.test.": {net.ParseIP("127.0.0.1")}})
+
+	for _, server := range []string{dns.udp, "tcp://" + dns.tcp, dns.doh} {
+		resolver, err := NewResolver(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := NewRequest().SetResolver(resolver).SetUrl("http://api.test:" + port + "/").Get().DoAndGetBody()
+		if err != nil {
+			t.Fatalf("%s: %v", server, err)
+		}
+		if string(body) != "api.test:"+port {
+			t.Fatalf("%s: unexpected host %q", server, body)
+		}
+	}
+
+	// UDP 响应被截断时改用 TCP
+	dns.truncate.Store(true)
+	resolver, _ := NewResolver("udp://" + dns.udp)
+	ips, err := resolver.LookupIP(context.Background(), "api.test")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("truncated fallback: %v %v", ips, err)
+	}
+
+	_, err = resolver.LookupIP(context.Background(), "missing.test")
+	if !errors.Is(err, ErrNoSuchHost) {
+		t.Fatalf("expected ErrNoSuchHost, got %v", err)
+	}
+	if _, err := NewResolver("ftp://127.0.0.1"); err == nil {
+		t.Fatal("expected unsupported scheme error")
+	}
+}
+
+func TestResolverFailover(t *testing.T) {
+	dns := newDNSStandIn(t, map[string][]net.IP{"api.test.": {net.ParseIP("127.0.0.1")}})
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := ln.Addr().String()
+	ln.Close()
+
+	resolver, _ := NewResolver("tcp://"+closed, dns.udp)
+	ips, err := resolver.LookupIP(context.Background(), "api.test")
+	if err != nil || len(ips) != 1 {
+		t.Fatalf("failover: %v %v", ips, err)
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	dns := newDNSStandIn(t, map[string][]net.IP{"api.test.": {net.ParseIP("127.0.0.1"), net.ParseIP("::1")}})
+	resolver, _ := NewResolver(dns.udp)
+	ctx := context.Background()
+
+	ips, err := resolver.LookupIP(ctx, "api.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// IPv6 排在前面
+	if len(ips) != 2 || ips[0].String() != "::1" {
+		t.Fatalf("unexpected order %v", ips)
+	}
+	if _, err := resolver.LookupIP(ctx, "API.test."); err != nil {
+		t.Fatal(err)
+	}
+	if n := dns.queries.Load(); n != 2 {
+		t.Fatalf("expected cached answer, got %d queries", n)
+	}
+
+	resolver.ClearCache()
+	resolver.LookupIP(ctx, "api.test")
+	if n := dns.queries.Load(); n != 4 {
+		t.Fatalf("expected requery after ClearCache, got %d queries", n)
+	}
+
+	// MaxTTL 限制缓存时长
+	resolver.MaxTTL = 50 * time.Millisecond
+	resolver.ClearCache()
+	resolver.LookupIP(ctx, "api.test")
+	time.Sleep(100 * time.Millisecond)
+	resolver.LookupIP(ctx, "api.test")
+	if n := dns.queries.Load(); n != 8 {
+		t.Fatalf("expected requery after TTL, got %d queries", n)
+	}
+
+	// TTL 为 0 的记录不缓存
+	dns.ttl.Store(0)
+	resolver.MaxTTL = 0
+	resolver.ClearCache()
+	resolver.LookupIP(ctx, "api.test")
+	resolver.LookupIP(ctx, "api.test")
+	if n := dns.queries.Load(); n != 12 {
+		t.Fatalf("zero TTL should not be cached, got %d queries", n)
+	}
+}
+
+func TestHappyEyeballs(t *testing.T) {
+	port := newHostEchoServer(t)
+	resolver, _ := NewResolver()
+	// 服务只监听 IPv4，首选的 IPv6 地址连接失败后使用 IPv4
+	resolver.AddHost("dual.test", "::1", "127.0.0.1")
+
+	for _, delay := range []time.Duration{0, -1, 50 * time.Millisecond} {
+		body, err := NewRequest().SetResolver(resolver).SetHappyEyeballs(delay).
+			SetUrl("http://dual.test:" + port + "/").Get().DoAndGetBody()
+		if err != nil {
+			t.Fatalf("delay %v: %v", delay, err)
+		}
+		if !strings.HasPrefix(string(body), "dual.test") {
+			t.Fatalf("delay %v: unexpected body %q", delay, body)
+		}
+	}
+
+	if _, err := NewRequest().SetResolver(resolver).SetIPFamily(IPv6Only).
+		SetUrl("http://dual.test:" + port + "/").Get().Do(); err == nil {
+		t.Fatal("expected IPv6-only dial to fail")
+	}
+}
+
+func TestGroupAddrs(t *testing.T) {
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1"), net.ParseIP("127.0.0.2")}
+	groups, err := groupAddrs(ips, "80", []string{"tcp"})
+	if err != nil || len(groups) != 2 || groups[0].network != "tcp4" || len(groups[0].addrs) != 2 {
+		t.Fatalf("unexpected groups %+v %v", groups, err)
+	}
+	groups, _ = groupAddrs(ips, "80", []string{"tcp6", "tcp4"})
+	if groups[0].network != "tcp6" || groups[0].addrs[0] != "[::1]:80" {
+		t.Fatalf("unexpected groups %+v", groups)
+	}
+	if _, err := groupAddrs(ips[:1], "80", []string{"tcp6"}); err == nil {
+		t.Fatal("expected no address error")
+	}
+}
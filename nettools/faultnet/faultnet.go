@@ -0,0 +1,311 @@
+// Package faultnet 提供用于混沌测试的故障注入 RoundTripper，
+// 可按概率或脚本顺序注入连接重置、响应体截断、慢读、TLS 失败、5xx 与延迟
+package faultnet
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Kind 表示故障类型
+type Kind int
+
+const (
+	KindNone        Kind = iota
+	KindLatency          // 延迟后正常转发
+	KindReset            // 连接被重置，不转发请求
+	KindPartialBody      // 响应体读取到一半时连接中断
+	KindSlowBody         // 响应体每次读取前等待
+	KindTLS              // TLS 握手失败，不转发请求
+	KindStatus           // 直接返回 5xx 响应，不转发请求
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNone:
+		return "none"
+	case KindLatency:
+		return "latency"
+	case KindReset:
+		return "reset"
+	case KindPartialBody:
+		return "partial-body"
+	case KindSlowBody:
+		return "slow-body"
+	case KindTLS:
+		return "tls"
+	case KindStatus:
+		return "status"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrInjected 表示由 faultnet 注入的错误，可用 errors.Is 区分真实故障
+var ErrInjected = errors.New("faultnet: 注入的故障")
+
+// InjectedError 表示注入的错误，同时包装底层错误以便按真实错误类型分类
+type InjectedError struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *InjectedError) Error() string {
+	return fmt.Sprintf("%v(%s): %v", ErrInjected, e.Kind, e.Err)
+}
+
+func (e *InjectedError) Unwrap() []error { return []error{ErrInjected, e.Err} }
+
+// Fault 表示一次要注入的故障，通过 Latency/Reset 等函数创建
+type Fault struct {
+	Kind       Kind
+	Latency    time.Duration // 任何故障前附加的延迟
+	StatusCode int           // KindStatus 返回的状态码
+	BodyBytes  int64         // KindPartialBody 中断前返回的字节数
+	ReadDelay  time.Duration // KindSlowBody 每次读取前的等待
+}
+
+// None 表示不注入故障，可在脚本中占位
+func None() Fault { return Fault{Kind: KindNone} }
+
+// Latency 在转发前等待 d
+func Latency(d time.Duration) Fault { return Fault{Kind: KindLatency, Latency: d} }
+
+// Reset 模拟连接被对端重置
+func Reset() Fault { return Fault{Kind: KindReset} }
+
+// PartialBody 返回 n 字节响应体后中断
+func PartialBody(n int64) Fault { return Fault{Kind: KindPartialBody, BodyBytes: n} }
+
+// SlowBody 每次读取响应体前等待 d
+func SlowBody(d time.Duration) Fault { return Fault{Kind: KindSlowBody, ReadDelay: d} }
+
+// TLSFailure 模拟 TLS 握手失败
+func TLSFailure() Fault { return Fault{Kind: KindTLS} }
+
+// Status 直接返回指定的 5xx 响应
+func Status(code int) Fault { return Fault{Kind: KindStatus, StatusCode: code} }
+
+// WithLatency 为故障附加延迟
+func (f Fault) WithLatency(d time.Duration) Fault {
+	f.Latency = d
+	return f
+}
+
+type rule struct {
+	probability float64
+	fault       Fault
+}
+
+// Transport 表示故障注入 RoundTripper，包裹真实的 RoundTripper 使用，可在多个协程中共享
+type Transport struct {
+	next   http.RoundTripper
+	mu     sync.Mutex
+	rand   *rand.Rand
+	rules  []rule
+	script []Fault
+	filter func(req *http.Request) bool
+	stats  map[Kind]int
+}
+
+// New 创建故障注入 Transport，next 为空时使用 http.DefaultTransport 的副本，
+// 以便 Req 沿 Unwrap 配置内层 Transport 时不影响全局默认值
+func New(next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	return &Transport{
+		next:  next,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		stats: make(map[Kind]int),
+	}
+}
+
+// WithProbability 以概率 p（0~1）注入故障，多条规则按添加顺序依次判定，最多命中一条
+func (t *Transport) WithProbability(p float64, fault Fault) *Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = append(t.rules, rule{probability: p, fault: fault})
+	return t
+}
+
+// WithScript 按顺序为之后的请求注入故障，脚本用完后回到概率规则
+func (t *Transport) WithScript(faults ...Fault) *Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.script = append(t.script, faults...)
+	return t
+}
+
+// WithSeed 固定随机种子，使概率注入可复现
+func (t *Transport) WithSeed(seed int64) *Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rand = rand.New(rand.NewSource(seed))
+	return t
+}
+
+// WithFilter 只对 filter 返回 true 的请求注入故障
+func (t *Transport) WithFilter(filter func(req *http.Request) bool) *Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.filter = filter
+	return t
+}
+
+// Stats 返回各类故障的注入次数
+func (t *Transport) Stats() map[Kind]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := make(map[Kind]int, len(t.stats))
+	for kind, n := range t.stats {
+		stats[kind] = n
+	}
+	return stats
+}
+
+// Unwrap 返回被包裹的 RoundTripper
+func (t *Transport) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+// pick 选出本次请求要注入的故障
+func (t *Transport) pick(req *http.Request) Fault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.filter != nil && !t.filter(req) {
+		return None()
+	}
+
+	fault := None()
+	if len(t.script) > 0 {
+		fault, t.script = t.script[0], t.script[1:]
+	} else {
+		for _, r := range t.rules {
+			if t.rand.Float64() < r.probability {
+				fault = r.fault
+				break
+			}
+		}
+	}
+	if fault.Kind != KindNone {
+		t.stats[fault.Kind]++
+	}
+	return fault
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := t.pick(req)
+	if fault.Latency > 0 {
+		if err := sleep(req.Context(), fault.Latency); err != nil {
+			return nil, err
+		}
+	}
+
+	switch fault.Kind {
+	case KindReset:
+		closeBody(req)
+		return nil, &InjectedError{Kind: KindReset, Err: &net.OpError{
+			Op:  "read",
+			Net: "tcp",
+			Err: os.NewSyscallError("read", syscall.ECONNRESET),
+		}}
+	case KindTLS:
+		closeBody(req)
+		// 40 为 handshake_failure 告警
+		return nil, &InjectedError{Kind: KindTLS, Err: tls.AlertError(40)}
+	case KindStatus:
+		closeBody(req)
+		return statusResponse(req, fault.StatusCode), nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch fault.Kind {
+	case KindPartialBody:
+		resp.Body = &partialBody{ReadCloser: resp.Body, remain: fault.BodyBytes}
+	case KindSlowBody:
+		resp.Body = &slowBody{ReadCloser: resp.Body, ctx: req.Context(), delay: fault.ReadDelay}
+	}
+	return resp, nil
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func statusResponse(req *http.Request, code int) *http.Response {
+	if code < 500 || code > 599 {
+		code = http.StatusServiceUnavailable
+	}
+	body := fmt.Sprintf("faultnet: injected %d", code)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// partialBody 返回 remain 字节后以 io.ErrUnexpectedEOF 中断
+type partialBody struct {
+	io.ReadCloser
+	remain int64
+}
+
+func (b *partialBody) Read(p []byte) (int, error) {
+	if b.remain <= 0 {
+		return 0, &InjectedError{Kind: KindPartialBody, Err: io.ErrUnexpectedEOF}
+	}
+	if int64(len(p)) > b.remain {
+		p = p[:b.remain]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remain -= int64(n)
+	return n, err
+}
+
+// slowBody 每次读取前等待，上下文取消时返回上下文错误
+type slowBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	delay time.Duration
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if err := sleep(b.ctx, b.delay); err != nil {
+		return 0, err
+	}
+	return b.ReadCloser.Read(p)
+}
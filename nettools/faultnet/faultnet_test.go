@@ -0,0 +1,111 @@
+package faultnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coutcin-xw/goutils/nettools"
+)
+
+func TestScriptedFaults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	faults := New(nil).WithScript(
+		Reset(),
+		TLSFailure(),
+		Status(502),
+		PartialBody(3),
+		SlowBody(100*time.Millisecond),
+		Latency(30*time.Millisecond),
+	)
+	do := func(ctx context.Context) (*http.Response, error) {
+		req := nettools.NewRequest().SetUrl(srv.URL).Get().SetContext(ctx)
+		req.Client.Transport = faults
+		return req.Do()
+	}
+	bg := context.Background()
+
+	_, err := do(bg)
+	if !errors.Is(err, ErrInjected) || nettools.ErrorKind(err) != nettools.ErrorKindConnection {
+		t.Fatalf("reset: %v (%s)", err, nettools.ErrorKind(err))
+	}
+	_, err = do(bg)
+	if !errors.Is(err, ErrInjected) || nettools.ErrorKind(err) != nettools.ErrorKindTLS {
+		t.Fatalf("tls: %v (%s)", err, nettools.ErrorKind(err))
+	}
+	resp, err := do(bg)
+	if err != nil || resp.StatusCode != 502 {
+		t.Fatalf("status: %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	resp, err = do(bg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if string(body) != "012" || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("partial body: %q %v", body, err)
+	}
+
+	ctx, cancel := context.WithTimeout(bg, 50*time.Millisecond)
+	defer cancel()
+	resp, err = do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow body should hit the deadline, got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := do(bg); err != nil || time.Since(start) < 30*time.Millisecond {
+		t.Fatalf("latency: %v after %s", err, time.Since(start))
+	}
+	// 脚本用完后不再注入
+	if _, err := do(bg); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := faults.Stats()
+	if stats[KindReset] != 1 || stats[KindStatus] != 1 || stats[KindLatency] != 1 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+}
+
+func TestProbabilityFaults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	faults := New(nil).WithSeed(1).WithProbability(0.5, Status(503)).
+		WithFilter(func(req *http.Request) bool { return req.URL.Path != "/health" })
+	client := &http.Client{Transport: faults}
+
+	failed := 0
+	for i := 0; i < 200; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == 503 {
+			failed++
+		}
+	}
+	if failed < 60 || failed > 140 {
+		t.Fatalf("expected roughly half of requests to fail, got %d", failed)
+	}
+
+	resp, err := client.Get(srv.URL + "/health")
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("filtered request should pass through: %v %v", resp, err)
+	}
+}
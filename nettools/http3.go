@@ -0,0 +1,308 @@
+package nettools
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+const (
+	altSvcDefaultMaxAge = 24 * time.Hour  // Alt-Svc 未指定 ma 时的有效期
+	http3BrokenTimeout  = 5 * time.Minute // QUIC 失败后暂停使用 HTTP/3 的时长
+)
+
+// http3HandshakeTimeout 为 QUIC 握手超时，超时后回落到 TCP
+var http3HandshakeTimeout = 5 * time.Second
+
+// altService 表示通过 Alt-Svc 发现的 HTTP/3 地址
+type altService struct {
+	addr    string
+	expires time.Time
+}
+
+// http3Transport 对通过 Alt-Svc 发现了 HTTP/3 的源站使用 QUIC，其余请求交给 next（HTTP/2 或 HTTP/1.1）。
+// QUIC 失败后在一段时间内不再尝试该源站的 HTTP/3，请求可以安全重放时回落到 next（见 replayable）；
+// only 为 true 时所有 HTTPS 请求直接使用 QUIC，不回落（WebSocket 握手除外）。经过代理以及使用 Unix 套接字、自定义拨号、源地址绑定的请求不使用 HTTP/3
+type http3Transport struct {
+	next     http.RoundTripper
+	h3       *http3.Transport
+	only     bool
+	tcpOnly  bool                                  // 配置了代理、自定义拨号或源地址绑定
+	proxy    func(*http.Request) (*url.URL, error) // 按请求判断的环境变量代理
+	insecure *tls.Config                           // InsecureHosts 使用的 TLS 配置
+	hosts    []string
+	resolver *Resolver
+
+	mu     sync.Mutex
+	alt    map[string]altService // 源站 host:port -> HTTP/3 地址
+	broken map[string]time.Time  // 源站 host:port -> 恢复尝试 HTTP/3 的时间
+}
+
+func (r *Req) newHTTP3Transport(next http.RoundTripper, inner *http.Transport) *http3Transport {
+	t := &http3Transport{
+		next: next,
+		only: r.HTTPVersion == HTTP3Only,
+		tcpOnly: r.ProxyPool != nil || len(r.ProxyChain) > 0 || r.Proxy != "" ||
+			r.UnixSocket != "" || r.DialContext != nil || r.LocalAddr != "" || r.Interface != "",
+		resolver: r.Resolver,
+		alt:      make(map[string]altService),
+		broken:   make(map[string]time.Time),
+	}
+	if r.ProxyFromEnv {
+		t.proxy = inner.Proxy
+	}
+	if r.Verify && len(r.InsecureHosts) > 0 && r.insecureTransport != nil {
+		t.insecure = r.insecureTransport.TLSClientConfig
+		t.hosts = r.InsecureHosts
+	}
+	t.h3 = &http3.Transport{
+		TLSClientConfig:    inner.TLSClientConfig,
+		QUICConfig:         &quic.Config{HandshakeIdleTimeout: http3HandshakeTimeout},
+		Dial:               t.dial,
+		DisableCompression: inner.DisableCompression,
+	}
+	return t
+}
+
+// dial 建立 QUIC 连接，源站有 Alt-Svc 地址时连接到该地址，证书仍按源站域名校验
+func (t *http3Transport) dial(ctx context.Context, addr string, config *tls.Config, quicConfig *quic.Config) (*quic.Conn, error) {
+	target := addr
+	t.mu.Lock()
+	if svc, ok := t.alt[addr]; ok && !t.only {
+		target = svc.addr
+	}
+	t.mu.Unlock()
+
+	if t.insecure != nil && MatchNoProxy(addr, t.hosts) {
+		insecure := t.insecure.Clone()
+		insecure.ServerName = config.ServerName
+		insecure.NextProtos = config.NextProtos
+		config = insecure
+	}
+	if t.resolver != nil {
+		host, port, err := net.SplitHostPort(target)
+		if err != nil {
+			return nil, &http3DialError{err: err}
+		}
+		ips, err := t.resolver.LookupIP(ctx, host)
+		if err != nil {
+			return nil, &http3DialError{err: err}
+		}
+		if len(ips) == 0 {
+			return nil, &http3DialError{err: fmt.Errorf("%w: %s", ErrNoSuchHost, host)}
+		}
+		target = net.JoinHostPort(ips[0].String(), port)
+	}
+	conn, err := quic.DialAddrEarly(ctx, target, config, quicConfig)
+	if err != nil {
+		return nil, &http3DialError{err: err}
+	}
+	return conn, nil
+}
+
+// http3DialError 表示 QUIC 连接建立失败，此时请求尚未发出
+type http3DialError struct {
+	err error
+}
+
+func (e *http3DialError) Error() string { return e.err.Error() }
+
+func (e *http3DialError) Unwrap() error { return e.err }
+
+// replayable 判断 QUIC 失败后能否通过 TCP 重新发送请求。与 net/http 相同，
+// 幂等方法以及带 Idempotency-Key 的请求总是可以重放；其余请求只在确认未发出时重放：
+// 连接建立或握手失败、服务器以 H3_REQUEST_REJECTED 拒绝处理。
+// 空闲超时可能发生在请求发出之后，不能据此判断服务器未处理
+func replayable(req *http.Request, err error) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+
+	var dialErr *http3DialError
+	var handshakeErr *quic.HandshakeTimeoutError
+	var h3Err *http3.Error
+	return errors.As(err, &dialErr) || errors.As(err, &handshakeErr) ||
+		errors.As(err, &h3Err) && h3Err.ErrorCode == http3.ErrCodeRequestRejected
+}
+
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isWebSocketUpgrade(req) {
+		return t.next.RoundTrip(req)
+	}
+	if req.URL.Scheme != "https" || t.skipQUIC(req) {
+		if t.only {
+			return nil, fmt.Errorf("HTTP/3 只支持直连的 HTTPS 请求: %s", req.URL.Redacted())
+		}
+		return t.next.RoundTrip(req)
+	}
+
+	origin := canonicalAuthority(req.URL)
+	if t.only || t.usable(origin) {
+		resp, err := t.h3.RoundTrip(req)
+		if err == nil {
+			t.observe(origin, resp)
+			return resp, nil
+		}
+		if t.only || req.Context().Err() != nil {
+			return nil, err
+		}
+		t.markBroken(origin)
+		// 非幂等请求可能已经发出，重放会导致服务器重复处理
+		if !replayable(req, err) {
+			return nil, err
+		}
+
+		// 请求体已被 QUIC 连接消费，无法重放时直接返回错误
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		t.observe(origin, resp)
+	}
+	return resp, err
+}
+
+func (t *http3Transport) skipQUIC(req *http.Request) bool {
+	if t.tcpOnly {
+		return true
+	}
+	if t.proxy == nil {
+		return false
+	}
+	proxyURL, err := t.proxy(req)
+	return err != nil || proxyURL != nil
+}
+
+// usable 判断源站是否有有效的 Alt-Svc 地址且未被标记为不可用
+func (t *http3Transport) usable(origin string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if until, ok := t.broken[origin]; ok {
+		if now.Before(until) {
+			return false
+		}
+		delete(t.broken, origin)
+	}
+	svc, ok := t.alt[origin]
+	if ok && now.After(svc.expires) {
+		delete(t.alt, origin)
+		return false
+	}
+	return ok
+}
+
+func (t *http3Transport) markBroken(origin string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.broken[origin] = time.Now().Add(http3BrokenTimeout)
+}
+
+// observe 根据响应的 Alt-Svc 头更新源站的 HTTP/3 地址
+func (t *http3Transport) observe(origin string, resp *http.Response) {
+	values := resp.Header.Values("Alt-Svc")
+	if len(values) == 0 {
+		return
+	}
+	host, _, _ := net.SplitHostPort(origin)
+	addr, maxAge, clear := parseAltSvc(values, host)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case clear:
+		delete(t.alt, origin)
+	case addr != "":
+		t.alt[origin] = altService{addr: addr, expires: time.Now().Add(maxAge)}
+	}
+}
+
+func (t *http3Transport) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+func (t *http3Transport) CloseIdleConnections() {
+	t.h3.CloseIdleConnections()
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// parseAltSvc 解析 Alt-Svc 头（RFC 7838），返回第一个 h3 地址与有效期；
+// 值为 clear 时表示源站撤销了全部备用服务
+func parseAltSvc(values []string, host string) (addr string, maxAge time.Duration, clear bool) {
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			params := strings.Split(entry, ";")
+			first := strings.TrimSpace(params[0])
+			if first == "clear" {
+				return "", 0, true
+			}
+			proto, authority, ok := strings.Cut(first, "=")
+			if !ok || strings.TrimSpace(proto) != http3.NextProtoH3 || addr != "" {
+				continue
+			}
+			authority = strings.Trim(strings.TrimSpace(authority), `"`)
+			altHost, port, err := net.SplitHostPort(authority)
+			if err != nil {
+				continue
+			}
+			if altHost == "" {
+				altHost = host
+			}
+			addr = net.JoinHostPort(altHost, port)
+			maxAge = altSvcDefaultMaxAge
+			for _, param := range params[1:] {
+				key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.TrimSpace(key) != "ma" {
+					continue
+				}
+				if seconds, err := strconv.ParseInt(strings.Trim(val, `"`), 10, 64); err == nil {
+					maxAge = time.Duration(seconds) * time.Second
+				}
+			}
+		}
+	}
+	return addr, maxAge, false
+}
+
+// canonicalAuthority 返回带端口的 host:port
+func canonicalAuthority(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,189 @@
+package nettools
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Server 启动同时监听 TCP 与 UDP 的测试服务器，TCP 响应通过 Alt-Svc 通告 HTTP/3 端口
+func newHTTP3Server(t *testing.T) (tcp *httptest.Server, h3 *http3.Server, udpAddr string, serverPEM []byte) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/abort" && r.ProtoMajor == 3 {
+			// 读取请求后中断 QUIC 流，模拟响应途中的失败
+			panic(http.ErrAbortHandler)
+		}
+		if len(data) == 0 {
+			w.Write([]byte(r.Proto))
+			return
+		}
+		fmt.Fprintf(w, "%s %s", r.Proto, data)
+	})
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udpAddr = udp.LocalAddr().String()
+	_, port, _ := net.SplitHostPort(udpAddr)
+
+	tcp, serverPEM = newTLSServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%s"; ma=60`, port))
+		handler(w, r)
+	}), withHTTP2)
+
+	h3 = &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: tcp.TLS.Certificates}),
+	}
+	go h3.Serve(udp)
+	t.Cleanup(func() {
+		h3.Close()
+		udp.Close()
+	})
+	return tcp, h3, udpAddr, serverPEM
+}
+
+func TestHTTP3AltSvc(t *testing.T) {
+	tcp, _, _, serverPEM := newHTTP3Server(t)
+
+	req := NewRequest().SetUrl(tcp.URL).Get().AddCACertPEM(serverPEM).SetHTTPVersion(HTTP3)
+	resp, err := req.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if GetProtocol(resp) != "HTTP/2.0" {
+		t.Fatalf("first request should use TCP, got %s", GetProtocol(resp))
+	}
+
+	// Alt-Svc 发现后切换到 HTTP/3，请求体可以正常发送
+	body, err := req.Post().SetData(map[string]interface{}{"k": "v"}).DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `HTTP/3.0 {"k":"v"}` {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestHTTP3Fallback(t *testing.T) {
+	old := http3HandshakeTimeout
+	http3HandshakeTimeout = 300 * time.Millisecond
+	defer func() { http3HandshakeTimeout = old }()
+
+	tcp, h3, _, serverPEM := newHTTP3Server(t)
+	req := NewRequest().SetUrl(tcp.URL).Get().AddCACertPEM(serverPEM).SetHTTPVersion(HTTP3)
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+
+	// QUIC 服务停止后回落到 TCP，请求体重新发送
+	h3.Close()
+	body, err := req.Post().SetData(map[string]interface{}{"k": "v"}).DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `HTTP/2.0 {"k":"v"}` {
+		t.Fatalf("unexpected fallback body %q", body)
+	}
+
+	// 标记为不可用期间直接使用 TCP
+	start := time.Now()
+	if body, err := req.Get().SetData(nil).DoAndGetBody(); err != nil || string(body) != "HTTP/2.0" {
+		t.Fatalf("broken origin: %q %v", body, err)
+	}
+	if time.Since(start) > 200*time.Millisecond {
+		t.Fatal("broken origin should skip QUIC")
+	}
+}
+
+func TestHTTP3FallbackAfterSend(t *testing.T) {
+	tcp, _, _, serverPEM := newHTTP3Server(t)
+	newReq := func() *Req {
+		req := NewRequest().SetUrl(tcp.URL).Get().AddCACertPEM(serverPEM).SetHTTPVersion(HTTP3)
+		if _, err := req.DoAndGetBody(); err != nil {
+			t.Fatal(err)
+		}
+		return req.SetUrl(tcp.URL + "/abort")
+	}
+
+	// 请求已经发出后失败，POST 不能通过 TCP 重放
+	body, err := newReq().Post().SetData(map[string]interface{}{"k": "v"}).DoAndGetBody()
+	if err == nil {
+		t.Fatalf("POST was replayed over TCP: %q", body)
+	}
+
+	// 幂等请求以及带 Idempotency-Key 的请求可以重放
+	if body, err := newReq().DoAndGetBody(); err != nil || string(body) != "HTTP/2.0" {
+		t.Fatalf("GET fallback: %q %v", body, err)
+	}
+	body, err = newReq().Post().SetHeader("Idempotency-Key", "1").
+		SetData(map[string]interface{}{"k": "v"}).DoAndGetBody()
+	if err != nil || string(body) != `HTTP/2.0 {"k":"v"}` {
+		t.Fatalf("idempotent POST fallback: %q %v", body, err)
+	}
+}
+
+func TestHTTP3ReplayableAndDial(t *testing.T) {
+	post := httptest.NewRequest(http.MethodPost, "https://example.com/", nil)
+	// 空闲超时可能发生在请求发出之后
+	if replayable(post, fmt.Errorf("wrap: %w", &quic.IdleTimeoutError{})) {
+		t.Fatal("idle timeout should not make POST replayable")
+	}
+	if !replayable(post, &quic.HandshakeTimeoutError{}) {
+		t.Fatal("handshake timeout should make POST replayable")
+	}
+
+	// 解析结果为空时返回连接错误而不是 panic
+	resolver, _ := NewResolver()
+	resolver.hosts["empty.test"] = []net.IP{}
+	h3 := &http3Transport{resolver: resolver, alt: make(map[string]altService)}
+	_, err := h3.dial(context.Background(), "empty.test:443", &tls.Config{}, nil)
+	var dialErr *http3DialError
+	if !errors.As(err, &dialErr) || !errors.Is(err, ErrNoSuchHost) {
+		t.Fatalf("expected dial error for empty lookup, got %v", err)
+	}
+}
+
+func TestHTTP3Only(t *testing.T) {
+	_, _, udpAddr, serverPEM := newHTTP3Server(t)
+
+	body, err := NewRequest().SetUrl("https://" + udpAddr + "/").Get().AddCACertPEM(serverPEM).
+		SetHTTPVersion(HTTP3Only).DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "HTTP/3.0" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	if _, err := NewRequest().SetUrl("http://" + udpAddr + "/").Get().SetHTTPVersion(HTTP3Only).Do(); err == nil {
+		t.Fatal("expected error for plain HTTP with HTTP3Only")
+	}
+}
+
+func TestParseAltSvc(t *testing.T) {
+	addr, maxAge, clear := parseAltSvc([]string{`h3-29=":8443", h3="alt.example.com:443"; ma=3600; persist=1`}, "example.com")
+	if addr != "alt.example.com:443" || maxAge != time.Hour || clear {
+		t.Fatalf("got %q %v %v", addr, maxAge, clear)
+	}
+	addr, maxAge, _ = parseAltSvc([]string{`h3=":443"`}, "example.com")
+	if addr != "example.com:443" || maxAge != altSvcDefaultMaxAge {
+		t.Fatalf("got %q %v", addr, maxAge)
+	}
+	if _, _, clear := parseAltSvc([]string{"clear"}, "example.com"); !clear {
+		t.Fatal("expected clear")
+	}
+}
@@ -0,0 +1,108 @@
+package nettools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// ErrBodyTooLarge 表示消息体超过了大小限制
+var ErrBodyTooLarge = errors.New("消息体超过大小限制")
+
+// BodyTooLargeError 表示超出限制的消息体，可用 errors.Is(err, ErrBodyTooLarge) 判断
+type BodyTooLargeError struct {
+	Limit         int64
+	ContentLength int64 // 通过 Content-Length 提前拒绝时为声明的长度，否则为 -1
+}
+
+func (e *BodyTooLargeError) Error() string {
+	if e.ContentLength >= 0 {
+		return fmt.Sprintf("%v: Content-Length %d 超过上限 %d", ErrBodyTooLarge, e.ContentLength, e.Limit)
+	}
+	return fmt.Sprintf("%v: 超过上限 %d", ErrBodyTooLarge, e.Limit)
+}
+
+func (e *BodyTooLargeError) Unwrap() error { return ErrBodyTooLarge }
+
+// defaultMaxBodySize 为 NewRequest 与 Read* 函数使用的默认上限，0 表示不限制
+var defaultMaxBodySize atomic.Int64
+
+// SetDefaultMaxBodySize 设置默认的消息体大小上限，对之后创建的请求以及
+// ReadRequest/ReadResponse/ReadResponseBody 生效，<=0 表示不限制
+func SetDefaultMaxBodySize(limit int64) {
+	defaultMaxBodySize.Store(limit)
+}
+
+// SetMaxBodySize 设置响应体大小上限（按解压后的大小计算），<=0 表示不限制；
+// 超出时 Do 或读取响应体返回 BodyTooLargeError
+func (r *Req) SetMaxBodySize(limit int64) *Req {
+	r.MaxBodySize = limit
+	return r
+}
+
+// limitResponse 根据 Content-Length 提前拒绝，并限制实际读取的长度
+func limitResponse(resp *http.Response, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	if err := checkContentLength(resp.ContentLength, limit); err != nil {
+		resp.Body.Close()
+		return err
+	}
+	resp.Body = &limitedBody{
+		limitedReader: limitedReader{r: resp.Body, limit: limit},
+		closer:        resp.Body,
+	}
+	return nil
+}
+
+func checkContentLength(contentLength, limit int64) error {
+	if limit > 0 && contentLength > limit {
+		return &BodyTooLargeError{Limit: limit, ContentLength: contentLength}
+	}
+	return nil
+}
+
+// limitedReader 读取超过 limit 字节时返回 BodyTooLargeError，
+// 为判断是否超限最多会从底层多读取 1 字节
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+	err   error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if remain := l.limit - l.n + 1; int64(len(p)) > remain {
+		p = p[:remain]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		l.err = &BodyTooLargeError{Limit: l.limit, ContentLength: -1}
+		return n - int(l.n-l.limit), l.err
+	}
+	return n, err
+}
+
+type limitedBody struct {
+	limitedReader
+	closer io.Closer
+}
+
+func (b *limitedBody) Close() error { return b.closer.Close() }
+
+// restoredBody 在读取中断时将已读内容与剩余内容拼接，保证消息体仍可完整读取
+type restoredBody struct {
+	io.Reader
+	io.Closer
+}
+
+func restoreBody(read io.Reader, rest io.ReadCloser) io.ReadCloser {
+	return &restoredBody{Reader: io.MultiReader(read, rest), Closer: rest}
+}
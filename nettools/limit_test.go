@@ -0,0 +1,124 @@
+package nettools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	payload := strings.Repeat("x", 100)
+	bomb, _ := compressBody(bytes.NewReader(make([]byte, 1<<20)), "gzip")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chunked":
+			w.Write([]byte(payload[:50]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(payload[50:]))
+		case "/bomb":
+			w.Header().Set("Content-Encoding", "gzip")
+			io.Copy(w, bomb)
+		default:
+			w.Write([]byte(payload))
+		}
+	}))
+	defer srv.Close()
+
+	// Content-Length 超限时直接拒绝
+	_, err := NewRequest().SetUrl(srv.URL).Get().SetMaxBodySize(10).Do()
+	var tooLarge *BodyTooLargeError
+	if !errors.Is(err, ErrBodyTooLarge) || !errors.As(err, &tooLarge) || tooLarge.ContentLength != 100 {
+		t.Fatalf("expected early rejection, got %v", err)
+	}
+
+	// 未声明长度以及解压后超限在读取时报错
+	for _, path := range []string{"/chunked", "/bomb"} {
+		if _, err := NewRequest().SetUrl(srv.URL + path).Get().SetMaxBodySize(64).DoAndGetBody(); !errors.Is(err, ErrBodyTooLarge) {
+			t.Fatalf("%s: expected body too large, got %v", path, err)
+		}
+	}
+
+	if body, err := NewRequest().SetUrl(srv.URL).Get().SetMaxBodySize(100).DoAndGetBody(); err != nil || len(body) != 100 {
+		t.Fatalf("body at the limit should pass: %d %v", len(body), err)
+	}
+}
+
+func TestReadHelpersLimit(t *testing.T) {
+	payload := strings.Repeat("y", 100)
+	newResp := func() *http.Response {
+		return &http.Response{
+			Proto:         "HTTP/1.1",
+			StatusCode:    http.StatusOK,
+			Header:        make(http.Header),
+			Body:          io.NopCloser(strings.NewReader(payload)),
+			ContentLength: -1,
+		}
+	}
+
+	for name, read := range map[string]func(*http.Response) error{
+		"ReadResponseBodyLimit": func(resp *http.Response) error { _, err := ReadResponseBodyLimit(resp, 10); return err },
+		"ReadResponseLimit":     func(resp *http.Response) error { _, err := ReadResponseLimit(resp, true, 10); return err },
+	} {
+		resp := newResp()
+		if err := read(resp); !errors.Is(err, ErrBodyTooLarge) {
+			t.Fatalf("%s: expected body too large, got %v", name, err)
+		}
+		// 超限后响应体仍然完整可读
+		if rest, _ := io.ReadAll(resp.Body); string(rest) != payload {
+			t.Fatalf("%s: body not restored, got %d bytes", name, len(rest))
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader(payload))
+	if _, err := ReadRequestLimit(req, false, 10); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected early rejection for request, got %v", err)
+	}
+
+	SetDefaultMaxBodySize(10)
+	defer SetDefaultMaxBodySize(0)
+	if _, err := ReadResponseBody(newResp()); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("default limit not applied: %v", err)
+	}
+}
+
+func TestMaxBodySizeWithCache(t *testing.T) {
+	payload := strings.Repeat("z", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/chunked" {
+			w.Write([]byte(payload[:50]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(payload[50:]))
+			return
+		}
+		w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+
+	// 超限的响应既不能绕过上限，也不能写入缓存
+	cache := NewCache(NewMemoryCache(0))
+	for _, path := range []string{"/", "/chunked", "/", "/chunked"} {
+		_, err := NewRequest().SetUrl(srv.URL + path).Get().SetCache(cache).SetMaxBodySize(64).DoAndGetBody()
+		if !errors.Is(err, ErrBodyTooLarge) {
+			t.Fatalf("%s: expected body too large, got %v", path, err)
+		}
+	}
+	if stats := cache.Stats(); stats.Stores != 0 || stats.Hits != 0 {
+		t.Fatalf("oversized responses were cached: %+v", stats)
+	}
+
+	// 未超限时照常缓存
+	for i := 0; i < 2; i++ {
+		body, err := NewRequest().SetUrl(srv.URL + "/chunked").Get().SetCache(cache).SetMaxBodySize(100).DoAndGetBody()
+		if err != nil || string(body) != payload {
+			t.Fatalf("body at the limit: %d %v", len(body), err)
+		}
+	}
+	if stats := cache.Stats(); stats.Stores != 1 || stats.Hits != 1 {
+		t.Fatalf("expected one store and one hit, got %+v", stats)
+	}
+}
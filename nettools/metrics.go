@@ -0,0 +1,188 @@
+package nettools
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RequestMetrics 表示一次请求的统计结果
+type RequestMetrics struct {
+	Host        string
+	Route       string // 通过 SetRoute 设置的路由模板，未设置时为空
+	Method      string
+	StatusCode  int           // 请求失败时为 0
+	StatusClass string        // "2xx"/"3xx"/"4xx"/"5xx"，请求失败时为 "error"
+	Duration    time.Duration // 包含限流等待时间
+	BytesOut    int64         // 请求体大小
+	BytesIn     int64         // 实际读取的响应体大小
+	Retry       bool          // 是否为重试请求，见 WithRetryAttempt
+	ErrorKind   string
+}
+
+// MetricsCollector 接收请求统计结果，实现需支持并发调用
+type MetricsCollector interface {
+	ObserveRequest(m RequestMetrics)
+}
+
+// MetricsCollectorFunc 允许使用普通函数作为 MetricsCollector
+type MetricsCollectorFunc func(m RequestMetrics)
+
+func (f MetricsCollectorFunc) ObserveRequest(m RequestMetrics) { f(m) }
+
+// 错误类型，用于指标与熔断等场景的失败分类
+const (
+	ErrorKindTimeout     = "timeout"
+	ErrorKindCanceled    = "canceled"
+	ErrorKindDNS         = "dns"
+	ErrorKindTLS         = "tls"
+	ErrorKindConnection  = "connection"
+	ErrorKindRateLimited = "rate_limited" // 被限流器拒绝，请求未发出
+	ErrorKindCircuitOpen = "circuit_open" // 被熔断器拒绝，请求未发出
+	ErrorKindOther       = "other"
+)
+
+// ErrorKind 将请求错误归类，err 为空时返回空字符串
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return ErrorKindRateLimited
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return ErrorKindCircuitOpen
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorKindCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorKindTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorKindDNS
+	}
+
+	var (
+		recordErr  tls.RecordHeaderError
+		alertErr   tls.AlertError
+		verifyErr  *tls.CertificateVerificationError
+		unknownCA  x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		invalidErr x509.CertificateInvalidError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownCA) || errors.As(err, &hostErr) || errors.As(err, &invalidErr) {
+		return ErrorKindTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return ErrorKindConnection
+	}
+	return ErrorKindOther
+}
+
+// StatusClass 返回状态码分类，如 "2xx"，非法状态码返回 "error"
+func StatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "error"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+func (r *Req) SetRoute(route string) *Req {
+	r.Route = route
+	return r
+}
+
+// SetMetrics 设置指标收集器，每次 Do 完成后上报统计结果
+func (r *Req) SetMetrics(collector MetricsCollector) *Req {
+	r.Metrics = collector
+	return r
+}
+
+type retryKey struct{}
+
+// WithRetryAttempt 标记请求为第 attempt 次重试（从 1 开始），供指标统计重试次数
+func WithRetryAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, retryKey{}, attempt)
+}
+
+// RetryAttempt 返回上下文中的重试次数，未标记时为 0
+func RetryAttempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(retryKey{}).(int)
+	return attempt
+}
+
+// metricsMiddleware 统计请求结果，响应体读取完毕或关闭时上报
+func metricsMiddleware(collector MetricsCollector, route string) Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			m := RequestMetrics{
+				Host:   req.URL.Host,
+				Route:  route,
+				Method: req.Method,
+				Retry:  RetryAttempt(req.Context()) > 0,
+			}
+			if req.ContentLength > 0 {
+				m.BytesOut = req.ContentLength
+			}
+
+			resp, err := next(req)
+			m.Duration = time.Since(start)
+			if err != nil {
+				m.StatusClass = "error"
+				m.ErrorKind = ErrorKind(err)
+				collector.ObserveRequest(m)
+				return resp, err
+			}
+
+			m.StatusCode = resp.StatusCode
+			m.StatusClass = StatusClass(resp.StatusCode)
+			resp.Body = &metricsBody{ReadCloser: resp.Body, collector: collector, metrics: m}
+			return resp, nil
+		}
+	}
+}
+
+type metricsBody struct {
+	io.ReadCloser
+	collector MetricsCollector
+	metrics   RequestMetrics
+	once      sync.Once
+}
+
+func (b *metricsBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.metrics.BytesIn += int64(n)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *metricsBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+func (b *metricsBody) done() {
+	b.once.Do(func() { b.collector.ObserveRequest(b.metrics) })
+}
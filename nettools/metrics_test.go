@@ -0,0 +1,127 @@
+package nettools
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestErrorKind(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{fmt.Errorf("请求执行失败: %w", &RateLimitError{Host: "a"}), ErrorKindRateLimited},
+		{&CircuitOpenError{Host: "a", State: CircuitOpen}, ErrorKindCircuitOpen},
+		{context.Canceled, ErrorKindCanceled},
+		{fmt.Errorf("wrap: %w", context.DeadlineExceeded), ErrorKindTimeout},
+		{&net.OpError{Op: "dial", Err: &timeoutError{}}, ErrorKindTimeout},
+		{&net.DNSError{Err: "no such host", Name: "x.invalid"}, ErrorKindDNS},
+		{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host"}}, ErrorKindDNS},
+		{x509.UnknownAuthorityError{}, ErrorKindTLS},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ErrorKindConnection},
+		{syscall.ECONNRESET, ErrorKindConnection},
+		{io.ErrUnexpectedEOF, ErrorKindConnection},
+		{errors.New("other"), ErrorKindOther},
+	} {
+		if got := ErrorKind(tc.err); got != tc.want {
+			t.Errorf("ErrorKind(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// metricsRecorder 收集上报的指标，供测试断言
+type metricsRecorder struct {
+	mu      sync.Mutex
+	metrics []RequestMetrics
+}
+
+func (c *metricsRecorder) ObserveRequest(m RequestMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = append(c.metrics, m)
+}
+
+func (c *metricsRecorder) last(t *testing.T) RequestMetrics {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.metrics) == 0 {
+		t.Fatal("no metrics recorded")
+	}
+	return c.metrics[len(c.metrics)-1]
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	collector := &metricsRecorder{}
+
+	// 响应体读取完毕后上报，包含路由、流量与重试标记
+	body, err := NewRequest().SetUrl(srv.URL + "/users/1").Post().
+		SetData(map[string]interface{}{"k": "v"}).
+		SetRoute("/users/{id}").SetMetrics(collector).
+		SetContext(WithRetryAttempt(context.Background(), 1)).
+		DoAndGetBody()
+	if err != nil || string(body) != "hello" {
+		t.Fatalf("%q %v", body, err)
+	}
+	m := collector.last(t)
+	if m.Host != host || m.Route != "/users/{id}" || m.Method != http.MethodPost ||
+		m.StatusCode != 200 || m.StatusClass != "2xx" || !m.Retry ||
+		m.BytesOut != int64(len(`{"k":"v"}`)) || m.BytesIn != int64(len("hello")) || m.ErrorKind != "" {
+		t.Fatalf("unexpected metrics: %+v", m)
+	}
+
+	// 被限流拒绝的请求同样上报
+	limiter := NewRateLimiter().SetPerHost(0.1, 1).SetFailFast(true)
+	for i := 0; i < 2; i++ {
+		NewRequest().SetUrl(srv.URL).Get().SetRateLimiter(limiter).SetMetrics(collector).DoAndGetBody()
+	}
+	if m := collector.last(t); m.StatusClass != "error" || m.ErrorKind != ErrorKindRateLimited {
+		t.Fatalf("rate limited request not recorded: %+v", m)
+	}
+
+	// 熔断打开后被拦截的请求同样上报
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Minute})
+	for i := 0; i < 2; i++ {
+		NewRequest().SetUrl(srv.URL + "/fail").Get().SetCircuitBreaker(breaker).SetMetrics(collector).DoAndGetBody()
+	}
+	if m := collector.last(t); m.StatusClass != "error" || m.ErrorKind != ErrorKindCircuitOpen {
+		t.Fatalf("circuit open request not recorded: %+v", m)
+	}
+
+	// 连接失败
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	NewRequest().SetUrl(closed.URL).Get().SetMetrics(collector).Do()
+	if m := collector.last(t); m.StatusCode != 0 || m.ErrorKind != ErrorKindConnection {
+		t.Fatalf("connection error not recorded: %+v", m)
+	}
+	if n := len(collector.metrics); n != 6 {
+		t.Fatalf("expected 6 observations, got %d", n)
+	}
+}
@@ -0,0 +1,45 @@
+package nettools
+
+import (
+	"net/http"
+)
+
+// DoFunc 表示一次完整的请求执行（包含重定向）
+type DoFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware 在请求执行前后插入逻辑，先添加的位于最外层
+type Middleware func(next DoFunc) DoFunc
+
+// Use 添加中间件，用于追踪、指标等横切逻辑
+func (r *Req) Use(middlewares ...Middleware) *Req {
+	r.Middlewares = append(r.Middlewares, middlewares...)
+	return r
+}
+
+// send 依次经过中间件后调用 Client.Do
+func (r *Req) send(req *http.Request) (*http.Response, error) {
+	do := DoFunc(r.Client.Do)
+	if r.CircuitBreaker != nil {
+		do = circuitBreakerMiddleware(r.CircuitBreaker)(do)
+	}
+	// 限流位于熔断外层，被限流或等待超时的请求没有发出，不计入熔断
+	if r.RateLimiter != nil {
+		do = rateLimitMiddleware(r.RateLimiter)(do)
+	}
+	// 指标位于限流与熔断外层，被拒绝的请求同样统计，耗时包含限流等待
+	if r.Metrics != nil {
+		do = metricsMiddleware(r.Metrics, r.Route)(do)
+	}
+	// 缓存命中时不占用限流额度，也不计入熔断
+	if r.Cache != nil {
+		do = cacheMiddleware(r.Cache, r.MaxBodySize)(do)
+	}
+	// 解压位于缓存外层，缓存中保存的是原始压缩内容
+	if !r.DisableDecompression {
+		do = decompressMiddleware(do)
+	}
+	for i := len(r.Middlewares) - 1; i >= 0; i-- {
+		do = r.Middlewares[i](do)
+	}
+	return do(req)
+}
@@ -0,0 +1,169 @@
+package mocknet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// Fixture 表示一条录制的请求与响应
+type Fixture struct {
+	Method     string      `json:"method"`
+	Path       string      `json:"path"` // 不含查询参数时匹配任意查询参数
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"` // 非 UTF-8 的响应体
+}
+
+func (f Fixture) body() []byte {
+	if f.BodyBase64 != "" {
+		data, _ := base64.StdEncoding.DecodeString(f.BodyBase64)
+		return data
+	}
+	return []byte(f.Body)
+}
+
+// LoadFixtures 从 JSON 文件读取录制数据
+func LoadFixtures(file string) ([]Fixture, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取录制文件失败: %w", err)
+	}
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("解析录制文件失败: %w", err)
+	}
+	return fixtures, nil
+}
+
+// SaveFixtures 将录制数据写入 JSON 文件
+func SaveFixtures(file string, fixtures []Fixture) error {
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码录制数据失败: %w", err)
+	}
+	return os.WriteFile(file, data, 0o644)
+}
+
+// Recorder 包裹真实的 RoundTripper，记录经过的请求与响应用于生成 Fixture
+type Recorder struct {
+	next     http.RoundTripper
+	mu       sync.Mutex
+	fixtures []Fixture
+}
+
+// NewRecorder 创建录制器，next 为空时使用 http.DefaultTransport 的副本
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	return &Recorder{next: next}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := Fixture{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+	}
+	fixture.Header.Del("Content-Length")
+	fixture.Header.Del("Date")
+	if utf8.Valid(body) {
+		fixture.Body = string(body)
+	} else {
+		fixture.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+
+	r.mu.Lock()
+	r.fixtures = append(r.fixtures, fixture)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Fixtures 返回已录制的数据
+func (r *Recorder) Fixtures() []Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Fixture(nil), r.fixtures...)
+}
+
+// Save 将已录制的数据写入 JSON 文件
+func (r *Recorder) Save(file string) error {
+	return SaveFixtures(file, r.Fixtures())
+}
+
+// Unwrap 返回被包裹的 RoundTripper
+func (r *Recorder) Unwrap() http.RoundTripper {
+	return r.next
+}
+
+// NewServer 启动按录制数据回放的 httptest 服务器，测试结束时自动关闭。
+// 同一请求有多条记录时依次返回，用完后重复最后一条；未匹配的请求返回 404 并使测试失败
+func NewServer(t testing.TB, fixtures ...Fixture) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	served := make(map[int]int) // 首条记录下标 -> 已回放次数
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		var matched []int
+		for i, f := range fixtures {
+			if f.Method == req.Method && (f.Path == req.URL.RequestURI() || f.Path == req.URL.Path) {
+				matched = append(matched, i)
+			}
+		}
+		if len(matched) == 0 {
+			mu.Unlock()
+			t.Errorf("mocknet: 没有匹配的录制数据: %s %s", req.Method, req.URL.RequestURI())
+			http.NotFound(w, req)
+			return
+		}
+		n := served[matched[0]]
+		served[matched[0]] = n + 1
+		fixture := fixtures[matched[min(n, len(matched)-1)]]
+		mu.Unlock()
+
+		for key, values := range fixture.Header {
+			w.Header()[key] = values
+		}
+		status := fixture.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		w.Write(fixture.body())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// NewServerFromFile 使用 JSON 录制文件启动回放服务器
+func NewServerFromFile(t testing.TB, file string) *httptest.Server {
+	t.Helper()
+	fixtures, err := LoadFixtures(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(t, fixtures...)
+}
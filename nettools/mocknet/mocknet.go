@@ -0,0 +1,321 @@
+// Package mocknet 为使用 nettools.Req 的代码提供测试替身：
+// 可编程的 RoundTripper 以及基于录制数据的 httptest 服务器
+package mocknet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ErrNoRoute 表示请求没有匹配的路由
+var ErrNoRoute = errors.New("mocknet: 没有匹配的路由")
+
+// Call 表示 Transport 收到的一次请求
+type Call struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+	Route  *Route // 未匹配时为 nil
+}
+
+// Transport 表示可编程的 http.RoundTripper，注入方式：req.Client.Transport = mock
+type Transport struct {
+	mu     sync.Mutex
+	routes []*Route
+	calls  []Call
+}
+
+// NewTransport 创建 Transport，未匹配的请求返回 ErrNoRoute
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// On 添加路由，method 为空或 "*" 时匹配任意方法；pattern 使用 path.Match 语法，
+// 以 "/" 开头时匹配 URL 路径，否则匹配 "host/path"。路由按添加顺序匹配
+func (m *Transport) On(method, pattern string) *Route {
+	route := &Route{
+		mu:      &m.mu,
+		method:  strings.ToUpper(method),
+		pattern: pattern,
+		status:  http.StatusOK,
+		header:  make(http.Header),
+	}
+	m.mu.Lock()
+	m.routes = append(m.routes, route)
+	m.mu.Unlock()
+	return route
+}
+
+// Calls 返回收到的全部请求
+func (m *Transport) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// Reset 清空路由与请求记录
+func (m *Transport) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = nil
+	m.calls = nil
+}
+
+func (m *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("mocknet: 读取请求体失败: %w", err)
+		}
+	}
+	call := Call{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	}
+
+	m.mu.Lock()
+	for _, route := range m.routes {
+		if route.match(req, body) {
+			route.calls++
+			call.Route = route
+			break
+		}
+	}
+	m.calls = append(m.calls, call)
+	m.mu.Unlock()
+
+	if call.Route == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoRoute, req.Method, req.URL)
+	}
+	return call.Route.respond(req)
+}
+
+// AssertCalled 断言 method 与 pattern（规则同 On）对应的请求恰好发生了 times 次
+func (m *Transport) AssertCalled(t testing.TB, method, pattern string, times int) {
+	t.Helper()
+	probe := &Route{method: strings.ToUpper(method), pattern: pattern}
+	count := 0
+	for _, call := range m.Calls() {
+		req, err := http.NewRequest(call.Method, call.URL, nil)
+		if err != nil {
+			continue
+		}
+		if probe.match(req, nil) {
+			count++
+		}
+	}
+	if count != times {
+		t.Errorf("mocknet: %s %s 期望调用 %d 次，实际 %d 次", method, pattern, times, count)
+	}
+}
+
+// AssertExpectations 断言每个路由都被调用过，设置了 Times 的路由需恰好达到次数
+func (m *Transport) AssertExpectations(t testing.TB) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, route := range m.routes {
+		switch {
+		case route.times > 0 && route.calls != route.times:
+			t.Errorf("mocknet: 路由 %s 期望调用 %d 次，实际 %d 次", route, route.times, route.calls)
+		case route.calls == 0:
+			t.Errorf("mocknet: 路由 %s 未被调用", route)
+		}
+	}
+}
+
+// Route 表示一条路由的匹配条件与响应，通过链式方法配置
+type Route struct {
+	mu       *sync.Mutex // 所属 Transport 的锁
+	method   string
+	pattern  string
+	headers  map[string]string
+	query    map[string]string
+	bodyFunc func(body []byte) bool
+
+	status  int
+	header  http.Header
+	body    []byte
+	handler func(req *http.Request) (*http.Response, error)
+	err     error
+	delay   time.Duration
+
+	times int // 最多匹配的次数，0 表示不限制
+	calls int
+}
+
+func (r *Route) String() string {
+	method := r.method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + r.pattern
+}
+
+// WithHeader 要求请求头 key 的值等于 value
+func (r *Route) WithHeader(key, value string) *Route {
+	if r.headers == nil {
+		r.headers = make(map[string]string)
+	}
+	r.headers[key] = value
+	return r
+}
+
+// WithQuery 要求查询参数 key 的值等于 value
+func (r *Route) WithQuery(key, value string) *Route {
+	if r.query == nil {
+		r.query = make(map[string]string)
+	}
+	r.query[key] = value
+	return r
+}
+
+// WithBodyContains 要求请求体包含 substr
+func (r *Route) WithBodyContains(substr string) *Route {
+	return r.WithBodyFunc(func(body []byte) bool {
+		return bytes.Contains(body, []byte(substr))
+	})
+}
+
+// WithBodyFunc 使用自定义函数匹配请求体
+func (r *Route) WithBodyFunc(match func(body []byte) bool) *Route {
+	r.bodyFunc = match
+	return r
+}
+
+// Times 限制路由最多匹配 n 次，用完后继续匹配后面的路由
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Reply 设置响应状态码与响应体
+func (r *Route) Reply(status int, body string) *Route {
+	r.status = status
+	r.body = []byte(body)
+	return r
+}
+
+// ReplyJSON 以 JSON 编码 v 作为响应体
+func (r *Route) ReplyJSON(status int, v interface{}) *Route {
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.err = fmt.Errorf("mocknet: 编码JSON失败: %w", err)
+		return r
+	}
+	r.header.Set("Content-Type", "application/json")
+	r.status = status
+	r.body = data
+	return r
+}
+
+// ReplyHeader 添加响应头
+func (r *Route) ReplyHeader(key, value string) *Route {
+	r.header.Add(key, value)
+	return r
+}
+
+// ReplyFunc 使用自定义函数生成响应
+func (r *Route) ReplyFunc(handler func(req *http.Request) (*http.Response, error)) *Route {
+	r.handler = handler
+	return r
+}
+
+// ReplyError 让请求直接返回 err，用于模拟网络错误
+func (r *Route) ReplyError(err error) *Route {
+	r.err = err
+	return r
+}
+
+// Delay 在响应前等待 d，期间请求上下文取消时返回上下文错误
+func (r *Route) Delay(d time.Duration) *Route {
+	r.delay = d
+	return r
+}
+
+// Calls 返回路由被匹配的次数
+func (r *Route) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func (r *Route) match(req *http.Request, body []byte) bool {
+	if r.times > 0 && r.calls >= r.times {
+		return false
+	}
+	if r.method != "" && r.method != "*" && r.method != req.Method {
+		return false
+	}
+	target := req.URL.Host + req.URL.Path
+	if strings.HasPrefix(r.pattern, "/") {
+		target = req.URL.Path
+	}
+	if ok, _ := path.Match(r.pattern, target); !ok {
+		return false
+	}
+	for key, value := range r.headers {
+		if req.Header.Get(key) != value {
+			return false
+		}
+	}
+	query := req.URL.Query()
+	for key, value := range r.query {
+		if query.Get(key) != value {
+			return false
+		}
+	}
+	return r.bodyFunc == nil || r.bodyFunc(body)
+}
+
+func (r *Route) respond(req *http.Request) (*http.Response, error) {
+	if r.delay > 0 {
+		timer := time.NewTimer(r.delay)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.handler != nil {
+		return r.handler(req)
+	}
+	return NewResponse(req, r.status, r.header, r.body), nil
+}
+
+// NewResponse 构造一个完整的响应，便于在 ReplyFunc 中使用
+func NewResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
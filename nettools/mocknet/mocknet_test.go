@@ -0,0 +1,95 @@
+package mocknet
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/coutcin-xw/goutils/nettools"
+)
+
+func newReq(mock *Transport, url string) *nettools.Req {
+	req := nettools.NewRequest().SetUrl(url).Get()
+	req.Client.Transport = mock
+	return req
+}
+
+func TestTransport(t *testing.T) {
+	mock := NewTransport()
+	mock.On("GET", "/users/*").WithHeader("Authorization", "Bearer x").Times(1).
+		ReplyJSON(200, map[string]int{"id": 1})
+	mock.On("GET", "/users/*").Reply(401, "unauthorized")
+	mock.On("*", "api.test/search").WithQuery("q", "go").ReplyHeader("X-Total", "3").Reply(200, "found")
+	mock.On("GET", "/slow").Delay(time.Second).Reply(200, "late")
+	mock.On("GET", "/reset").ReplyError(syscall.ECONNRESET)
+
+	var user struct{ ID int }
+	if err := newReq(mock, "http://api.test/users/1").SetHeader("Authorization", "Bearer x").DoAndUnmarshal(&user); err != nil || user.ID != 1 {
+		t.Fatalf("user=%+v err=%v", user, err)
+	}
+	// Times 用完后落到下一条路由
+	resp, err := newReq(mock, "http://api.test/users/2").SetHeader("Authorization", "Bearer x").Do()
+	if err != nil || resp.StatusCode != 401 {
+		t.Fatalf("expected fallback route, got %v %v", resp, err)
+	}
+
+	resp, err = newReq(mock, "http://api.test/search").SetParams(map[string]interface{}{"q": "go"}).Do()
+	if err != nil || resp.Header.Get("X-Total") != "3" {
+		t.Fatalf("search: %v %v", resp, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := newReq(mock, "http://api.test/slow").SetContext(ctx).Do(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if _, err := newReq(mock, "http://api.test/reset").Do(); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+	if _, err := newReq(mock, "http://api.test/unknown").Do(); !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("expected ErrNoRoute, got %v", err)
+	}
+
+	mock.AssertCalled(t, "GET", "/users/*", 2)
+	mock.AssertExpectations(t)
+	if calls := mock.Calls(); len(calls) != 6 || calls[0].Header.Get("Authorization") != "Bearer x" {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+}
+
+func TestFixtureServer(t *testing.T) {
+	live := NewServer(t,
+		Fixture{Method: "GET", Path: "/items", Status: 200, Body: "first"},
+		Fixture{Method: "GET", Path: "/items", Status: 200, Body: "second"},
+		Fixture{Method: "GET", Path: "/bin", Status: 200, BodyBase64: "/wA="},
+	)
+
+	// 录制一次真实交互，保存后用于回放
+	recorder := NewRecorder(nil)
+	for _, path := range []string{"/items", "/items?page=2", "/bin"} {
+		req := nettools.NewRequest().SetUrl(live.URL + path).Get()
+		req.Client.Transport = recorder
+		if _, err := req.DoAndGetBody(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := recorder.Save(file); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := NewServerFromFile(t, file)
+	for _, c := range []struct{ path, want string }{
+		{"/items", "first"},
+		{"/items?page=2", "second"},
+		{"/bin", "\xff\x00"},
+	} {
+		body, err := nettools.NewRequest().SetUrl(replay.URL + c.path).Get().DoAndGetBody()
+		if err != nil || string(body) != c.want {
+			t.Fatalf("%s: %q %v", c.path, body, err)
+		}
+	}
+}
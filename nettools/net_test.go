@@ -1,8 +1,10 @@
 package nettools
 
 import (
-	"os"
+	"strings"
 	"testing"
+
+	"github.com/coutcin-xw/goutils/nettools/mocknet"
 )
 
 func TestNetTools_Console(t *testing.T) {
@@ -11,37 +13,37 @@ func TestNetTools_Console(t *testing.T) {
 	// ext, _ := GetURIExtension(url1)
 	// logs.Log.Console(ext)
 
-	// 创建请求
-	// req := NewRequest().
-	// 	SetUrl("https://www.baidu.com/data").
-	// 	Get().
-	// 	SetParams(map[string]interface{}{
-	// 		"page": 1,
-	// 		"size": 20,
-	// 	}).
-	// 	SetHeader("Authorization", "Bearer token")
+	// 上传文件，使用 mocknet 代替真实服务
+	mock := mocknet.NewTransport()
+	mock.On("POST", "api.example.com/upload").
+		WithBodyContains("example file").
+		ReplyJSON(200, map[string]string{"status": "ok"})
 
-	// resp, _ := req.Do()
-	// reqs, _ := ReadRequest(req.Requests, false)
-	// 上传文件
-	file, _ := os.Open("test.txt")
-	resp, err := NewRequest().
+	req := NewRequest().
 		SetUrl("https://api.example.com/upload").
 		Post().
-		AddFile("file", "test.txt", file).
+		AddFile("file", "test.txt", strings.NewReader("hello")).
 		SetData(map[string]interface{}{
 			"description": "example file",
-		}).
-		Do()
+		})
+	req.Client.Transport = mock
+	resp, err := req.Do()
 	if err != nil {
-
+		t.Fatal(err)
 	}
 	defer resp.Body.Close()
 
-	// body, _ := io.ReadAll(resp.Body)
-	body, _ := ReadResponse(resp, true)
-	// body2, _ := ReadResponse(resp, false)
-	println(string(body))
+	body, err := ReadResponse(resp, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `{"status":"ok"}`) {
+		t.Fatalf("unexpected response %q", body)
+	}
 
-	// println(string(reqs))
+	reqs, err := ReadRequest(req.Requests, false)
+	if err != nil || !strings.HasPrefix(string(reqs), "POST /upload HTTP/1.1") {
+		t.Fatalf("unexpected request dump %q: %v", reqs, err)
+	}
+	mock.AssertExpectations(t)
 }
@@ -1,15 +1,20 @@
 package nettools
 
 import (
+	"context"
+	"crypto/tls"
 	"io"
 	"net/http"
 	"net/http/cookiejar"
+	"sync"
 	"time"
 )
 
-// Req 表示一个可链式调用的HTTP请求构建器
+// Req 表示一个可链式调用的HTTP请求构建器。
+// 配置完成后可在多个协程中同时调用 Do；需要在各协程中修改配置时，先用 Clone 复制一份
 type Req struct {
 	Client    *http.Client
+	Ctx       context.Context // 为空时使用 context.Background()
 	Requests  *http.Request
 	Method    string
 	Url       string
@@ -18,10 +23,62 @@ type Req struct {
 	Headers   map[string]string
 	Cookies   []*http.Cookie
 	Files     []*RequestFile // 改为支持多个文件
-	Verify    bool
+	Verify    bool           // 默认开启证书校验，见 SetLegacyInsecureDefault
 	CertPaths []string
 	Proxy     string // 改为单个代理URL
 	Timeout   time.Duration
+	Redirect  *RedirectPolicy // 为空时沿用 http.Client 默认的重定向行为
+	Trace     bool            // 记录耗时分解，通过 GetTraceInfo 获取
+
+	Middlewares    []Middleware     // 包裹请求执行的中间件
+	Metrics        MetricsCollector // 请求指标收集器
+	Route          string           // 指标中使用的路由模板，如 "/users/{id}"
+	RateLimiter    *RateLimiter     // 可在多个请求间共享的限流器
+	CircuitBreaker *CircuitBreaker  // 按主机熔断，可在多个请求间共享
+	Cache          *Cache           // HTTP 响应缓存，可在多个请求间共享
+
+	TransportWrappers []func(next http.RoundTripper) http.RoundTripper // Transport 包装层，见 WrapTransport
+
+	DisableDecompression bool   // 关闭 gzip/deflate/br/zstd 自动解压
+	RequestEncoding      string // 请求体压缩算法，为空时不压缩
+	Charset              string // DoAndGetText 使用的字符集，为空时自动探测
+	MaxBodySize          int64  // 响应体大小上限，<=0 表示不限制
+
+	HTTPVersion HTTPVersion      // 协议版本，默认自动协商
+	ConnPool    *ConnPoolConfig  // 连接池参数，为空时使用 Transport 默认值
+	WebSocket   *WebSocketConfig // DialWebSocket 的连接参数
+
+	UnixSocket  string   // 通过 Unix 套接字发送请求，以 "@" 开头表示 Linux 抽象套接字
+	DialContext DialFunc // 自定义拨号函数
+	LocalAddr   string   // 出站连接绑定的源 IP
+	Interface   string   // 出站连接绑定的网卡
+	IPFamily    IPFamily // 出站连接的地址族偏好
+
+	Resolver      *Resolver     // 自定义 DNS 解析器，可在多个请求间共享
+	FallbackDelay time.Duration // Happy Eyeballs 启动备用地址族前的等待时间，0 为 300ms，<0 表示依次尝试
+
+	ProxyChain   []string          // 代理链，按顺序依次穿过，优先于 Proxy
+	ProxyHeaders map[string]string // HTTP CONNECT 代理的附加请求头
+	ProxyPool    *ProxyPool        // 轮询代理池，优先于 ProxyChain/Proxy
+	ProxyFromEnv bool              // 未设置其他代理时读取环境变量
+	NoProxy      []string          // 不走代理的域名/IP/CIDR
+
+	ClientCerts   []tls.Certificate // 双向 TLS 客户端证书
+	CACertPEMs    [][]byte          // 内存中的 PEM 格式 CA 证书
+	MinTLSVersion uint16
+	MaxTLSVersion uint16
+	CipherSuites  []uint16
+	ServerName    string   // 覆盖 SNI
+	PinnedSPKI    []string // 证书公钥固定（SHA-256 Base64）
+	InsecureHosts []string // 在开启校验时仍跳过校验的主机
+
+	tlsErr            error            // 链式设置 TLS 选项时产生的错误，在 Do 时返回
+	cloneErr          error            // Clone 时组装模板 Transport 产生的错误，在副本的 Do 中返回
+	insecureTransport *http.Transport  // 供 InsecureHosts 使用的 Transport
+	transportStale    bool             // TLS/代理等配置已修改，需要重新组装 Transport
+	appliedConfig     *transportConfig // 上次组装 Transport 时的配置，与当前字段不一致时重新组装
+	appliedRedirect   *RedirectPolicy  // 已设置到 Client.CheckRedirect 的策略
+	mu                sync.Mutex       // 保护 Do 中对 Client、Requests 等字段的写入
 }
 
 // RequestFile 表示要上传的文件
@@ -40,5 +97,7 @@ func NewRequest() *Req {
 			Jar:     jar,
 			Timeout: 30 * time.Second,
 		},
+		Verify:      !legacyInsecureDefault.Load(),
+		MaxBodySize: defaultMaxBodySize.Load(),
 	}
 }
@@ -36,175 +36,161 @@ func RemoveQueryParams(urlStr string) (string, error) {
 	return parsedUrl.String(), nil
 }
 
-// ReadRequest 打印请求的所有内容并返回结果
+// 截断模式下展示的消息体长度
+const cutBodyLen = 100
+
+// ReadRequest 打印请求的所有内容并返回结果，请求体大小受 SetDefaultMaxBodySize 限制
 func ReadRequest(req *http.Request, isCut bool) ([]byte, error) {
-	// 使用一个缓冲区保存请求内容
-	var requestDetails bytes.Buffer
-	var bodyCopy bytes.Buffer
-	var body bytes.Buffer
-	// 修复1: 包含完整的URL路径和参数
-	urlPart := req.URL.Path
-	if req.URL.RawQuery != "" {
-		urlPart += "?" + req.URL.RawQuery
-	}
-	// 打印请求方法和 URL
-	requestDetails.WriteString(fmt.Sprintf("%s %s %s\r\n", req.Method, urlPart, req.Proto))
+	return ReadRequestLimit(req, isCut, defaultMaxBodySize.Load())
+}
 
-	req.Header.Add("Host", req.URL.Host)
-	// 打印请求头
-	for key, values := range req.Header {
-		for _, value := range values {
-			requestDetails.WriteString(fmt.Sprintf("%s: %s\r\n", key, value))
-		}
+// ReadRequestLimit 同 ReadRequest，请求体超过 limit 字节时返回 BodyTooLargeError，<=0 表示不限制。
+// 请求体读取后替换为 ReplayableBody，并设置 GetBody 以便重新发送
+func ReadRequestLimit(req *http.Request, isCut bool, limit int64) ([]byte, error) {
+	if err := checkContentLength(req.ContentLength, limit); err != nil {
+		return nil, err
 	}
 
-	// 如果请求体不为空，才进行读取
-	if req.Body != nil {
-		// 创建一个 TeeReader 复制数据
-		tee := io.TeeReader(req.Body, &bodyCopy)
-
-		// 根据需要逐块读取请求体（避免内存占用过高）
-		chunkSize := 8192
-		buf := make([]byte, chunkSize)
-		var totalRead int
-		var isRead int = 1
-		for {
-			n, err := tee.Read(buf)
-			if n > 0 {
-				totalRead += n
-				if isCut && totalRead > 100 {
-					if isRead >= 1 {
-						body.Write(buf[:100-totalRead+n]) // 只截断到 100 字节
-						isRead--
-					} else {
-						continue
-					}
-
-				} else {
-					body.Write(buf[:n])
-				}
-			}
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return nil, fmt.Errorf("failed to read request body: %v", err)
+	var body *ReplayableBody
+	if req.Body != nil && req.Body != http.NoBody {
+		replay, restore, err := bufferBody(req.Body, req.ContentLength, limit)
+		if err != nil {
+			if restore != nil {
+				req.Body = restore
 			}
+			return nil, fmt.Errorf("failed to read request body: %w", err)
 		}
-		// 重新设置请求体，确保后续逻辑能继续使用
-		req.Body = io.NopCloser(&bodyCopy)
-		// 将读取的请求体内容放到缓冲区
-		if body.Len() > 0 {
-			requestDetails.WriteString(fmt.Sprintf("\r\n%s", body.String()))
-		} else {
-			requestDetails.WriteString("\r\n")
+		req.Body = replay
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(replay.NewReader()), nil
 		}
-	} else {
-		// 如果没有请求体，直接写入换行符
-		requestDetails.WriteString("\r\n")
+		body = replay
 	}
 
-	// 返回请求内容的字节数组
+	// 包含完整的URL路径和参数
+	urlPart := req.URL.Path
+	if req.URL.RawQuery != "" {
+		urlPart += "?" + req.URL.RawQuery
+	}
+
+	var requestDetails bytes.Buffer
+	requestDetails.Grow(detailsSize(req.Header, body, isCut))
+	// 打印请求方法和 URL
+	fmt.Fprintf(&requestDetails, "%s %s %s\r\n", req.Method, urlPart, req.Proto)
+
+	req.Header.Add("Host", req.URL.Host)
+	writeHeader(&requestDetails, req.Header)
+	writeBody(&requestDetails, body, isCut)
 	return requestDetails.Bytes(), nil
 }
 
-// 打印响应内容
+// 打印响应内容，响应体大小受 SetDefaultMaxBodySize 限制
 func ReadResponse(resp *http.Response, isCut bool) ([]byte, error) {
-	// 使用一个缓冲区保存响应内容
+	return ReadResponseLimit(resp, isCut, defaultMaxBodySize.Load())
+}
+
+// ReadResponseLimit 同 ReadResponse，响应体超过 limit 字节时返回 BodyTooLargeError，<=0 表示不限制。
+// 响应体读取后替换为 ReplayableBody，可继续读取
+func ReadResponseLimit(resp *http.Response, isCut bool, limit int64) ([]byte, error) {
+	// 展示解压后的响应体
+	DecompressResponse(resp)
+	body, err := bufferResponseBody(resp, limit)
+	if err != nil {
+		return nil, err
+	}
+
 	var responseDetails bytes.Buffer
-	var bodyCopy bytes.Buffer
-	var body bytes.Buffer
+	responseDetails.Grow(detailsSize(resp.Header, body, isCut))
 	// 打印响应状态码和状态文本
-	responseDetails.WriteString(fmt.Sprintf("%s %d %s\r\n", resp.Proto, resp.StatusCode, http.StatusText(resp.StatusCode)))
+	fmt.Fprintf(&responseDetails, "%s %d %s\r\n", resp.Proto, resp.StatusCode, http.StatusText(resp.StatusCode))
+	writeHeader(&responseDetails, resp.Header)
+	writeBody(&responseDetails, body, isCut)
+	return responseDetails.Bytes(), nil
+}
 
-	// 打印响应头
-	for key, values := range resp.Header {
-		for _, value := range values {
-			responseDetails.WriteString(fmt.Sprintf("%s: %s\r\n", key, value))
-		}
-	}
-	// 如果请求体不为空，才进行读取
-	if resp.Body != nil {
-		// 创建一个 TeeReader 复制数据
-		tee := io.TeeReader(resp.Body, &bodyCopy)
-
-		// 根据需要逐块读取（避免内存占用过高）
-		chunkSize := 8192
-		buf := make([]byte, chunkSize)
-		var totalRead int
-		var isRead int = 1
-		for {
-			n, err := tee.Read(buf)
-			if n > 0 {
-				totalRead += n
-				if isCut && totalRead > 100 {
-					if isRead >= 1 {
-						body.Write(buf[:100-totalRead+n]) // 只截断到 100 字节
-						isRead--
-					} else {
-						continue
-					}
-				} else {
-					body.Write(buf[:n])
-				}
-			}
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
+// ReadResponseBody 读取 HTTP 响应体并返回为字符串，大小受 SetDefaultMaxBodySize 限制
+func ReadResponseBody(resp *http.Response) ([]byte, error) {
+	return ReadResponseBodyLimit(resp, defaultMaxBodySize.Load())
+}
 
-				return nil, fmt.Errorf("failed to read response body: %v", err)
-			}
+// ReadResponseBodyLimit 同 ReadResponseBody，响应体超过 limit 字节时返回 BodyTooLargeError，<=0 表示不限制。
+// 内容保存在内存中时直接返回与响应体共享的切片，调用方不应修改
+func ReadResponseBodyLimit(resp *http.Response, limit int64) ([]byte, error) {
+	// 压缩的响应体先解压
+	DecompressResponse(resp)
+	body, err := bufferResponseBody(resp, limit)
+	if err != nil || body == nil {
+		return nil, err
+	}
+	if !body.Spilled() {
+		return body.Bytes(), nil
+	}
+	return io.ReadAll(body.NewReader())
+}
 
+// bufferResponseBody 将响应体替换为 ReplayableBody，没有响应体时返回 nil
+func bufferResponseBody(resp *http.Response, limit int64) (*ReplayableBody, error) {
+	if err := checkContentLength(resp.ContentLength, limit); err != nil {
+		return nil, err
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil, nil
+	}
+	replay, restore, err := bufferBody(resp.Body, resp.ContentLength, limit)
+	if err != nil {
+		if restore != nil {
+			resp.Body = restore
 		}
-		// 重新设置响应体，确保后续逻辑能继续使用
-		resp.Body = io.NopCloser(&bodyCopy)
-		// 将读取的响应体内容放到缓冲区
-		if body.Len() > 0 {
-			responseDetails.WriteString(fmt.Sprintf("\r\n%s", body.String()))
-		} else {
-			responseDetails.WriteString("\r\n")
-		}
-	} else {
-		// 如果没有请求体，直接写入换行符
-		responseDetails.WriteString("\r\n")
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
+	resp.Body = replay
+	return replay, nil
+}
 
-	return responseDetails.Bytes(), nil
+func writeHeader(buf *bytes.Buffer, header http.Header) {
+	for key, values := range header {
+		for _, value := range values {
+			buf.WriteString(key)
+			buf.WriteString(": ")
+			buf.WriteString(value)
+			buf.WriteString("\r\n")
+		}
+	}
 }
 
-// ReadResponseBody 读取 HTTP 响应体并返回为字符串
-func ReadResponseBody(resp *http.Response) ([]byte, error) {
-	// 使用一个缓冲区保存响应内容
-	var responseDetails bytes.Buffer
+// writeBody 在空行后写入消息体，截断模式下只写入前 cutBodyLen 字节
+func writeBody(buf *bytes.Buffer, body *ReplayableBody, isCut bool) {
+	buf.WriteString("\r\n")
+	if body == nil {
+		return
+	}
+	n := body.Len()
+	if isCut && n > cutBodyLen {
+		n = cutBodyLen
+	}
+	if !body.Spilled() {
+		buf.Write(body.Bytes()[:n])
+		return
+	}
+	io.CopyN(buf, body.NewReader(), n)
+}
 
-	// 创建一个临时缓冲区保存响应体内容
-	var bodyBuffer bytes.Buffer
-
-	// 使用 io.TeeReader 同时读取响应体并复制内容到 bodyBuffer
-	reader := io.TeeReader(resp.Body, &bodyBuffer)
-	// 重新设置响应体，确保后续逻辑能继续使用
-	resp.Body = io.NopCloser(&bodyBuffer)
-	// 分块读取响应体内容，避免一次性读取过大的文件
-	chunkSize := 8192 // 每次读取 8KB
-	buf := make([]byte, chunkSize)
-
-	for {
-		n, err := reader.Read(buf)
-		if n > 0 {
-			responseDetails.Write(buf[:n]) // 将内容写入字符串缓冲区
-		}
-		if err == io.EOF {
-			break // 读取完成
+// detailsSize 估算输出内容的长度，避免缓冲区多次扩容
+func detailsSize(header http.Header, body *ReplayableBody, isCut bool) int {
+	size := 128
+	for key, values := range header {
+		for _, value := range values {
+			size += len(key) + len(value) + 4
 		}
-		if err != nil {
-
-			return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if body != nil {
+		if isCut && body.Len() > cutBodyLen {
+			size += cutBodyLen
+		} else {
+			size += int(body.Len())
 		}
 	}
-
-	// 返回读取到的响应体
-	return responseDetails.Bytes(), nil
+	return size
 }
 
 // 判断 IP 是否在列表中
@@ -0,0 +1,196 @@
+// Package otelnet 为 nettools.Req 提供 OpenTelemetry 追踪与指标中间件
+package otelnet
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coutcin-xw/goutils/nettools"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scopeName = "github.com/coutcin-xw/goutils/nettools/otelnet"
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+	rawQuery       bool
+}
+
+// Option 配置中间件
+type Option func(*config)
+
+// WithTracerProvider 指定 TracerProvider，默认使用 otel.GetTracerProvider()
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.tracerProvider = tp }
+}
+
+// WithMeterProvider 指定 MeterProvider，默认使用 otel.GetMeterProvider()
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) { c.meterProvider = mp }
+}
+
+// WithPropagator 指定上下文传播方式，默认使用 W3C traceparent
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) { c.propagator = p }
+}
+
+// WithRawQuery 让 url.full 保留原始查询参数值；默认值会被替换为 REDACTED，
+// 避免签名、令牌等敏感参数写入追踪数据
+func WithRawQuery() Option {
+	return func(c *config) { c.rawQuery = true }
+}
+
+type instruments struct {
+	duration     metric.Float64Histogram
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+}
+
+// Middleware 返回为每次 Req.Do 创建客户端 Span、注入 traceparent 并记录耗时与大小直方图的中间件
+func Middleware(opts ...Option) nettools.Middleware {
+	cfg := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagator:     propagation.TraceContext{},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	tracer := cfg.tracerProvider.Tracer(scopeName)
+	meter := cfg.meterProvider.Meter(scopeName)
+
+	// 创建失败时 otel 会返回可用的空实现，这里忽略错误
+	var inst instruments
+	inst.duration, _ = meter.Float64Histogram("http.client.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("HTTP 客户端请求耗时"))
+	inst.requestSize, _ = meter.Int64Histogram("http.client.request.body.size",
+		metric.WithUnit("By"), metric.WithDescription("HTTP 客户端请求体大小"))
+	inst.responseSize, _ = meter.Int64Histogram("http.client.response.body.size",
+		metric.WithUnit("By"), metric.WithDescription("HTTP 客户端响应体大小"))
+
+	return func(next nettools.DoFunc) nettools.DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			attrs := requestAttributes(req)
+
+			ctx, span := tracer.Start(req.Context(), req.Method,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(append(attrs, semconv.URLFull(fullURL(req.URL, cfg.rawQuery)))...))
+			req = req.WithContext(ctx)
+			cfg.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+			resp, err := next(req)
+
+			// 响应相关属性同时写入 Span 与指标，url.full 基数过高不进入指标
+			var respAttrs []attribute.KeyValue
+			if err != nil {
+				respAttrs = append(respAttrs, semconv.ErrorTypeKey.String(errorType(err)))
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			} else {
+				respAttrs = append(respAttrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+				if resp.StatusCode >= 400 {
+					respAttrs = append(respAttrs, semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+					span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+				}
+			}
+			span.SetAttributes(respAttrs...)
+			span.End()
+			attrs = append(attrs, respAttrs...)
+
+			set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+			inst.duration.Record(ctx, time.Since(start).Seconds(), set)
+			if req.ContentLength > 0 {
+				inst.requestSize.Record(ctx, req.ContentLength, set)
+			}
+			if resp != nil && resp.Body != nil {
+				resp.Body = &countingBody{ReadCloser: resp.Body, onDone: func(n int64) {
+					inst.responseSize.Record(ctx, n, set)
+				}}
+			}
+			return resp, err
+		}
+	}
+}
+
+func requestAttributes(req *http.Request) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.ServerAddress(req.URL.Hostname()),
+	}
+	port := req.URL.Port()
+	if port == "" {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.ServerPort(p))
+	}
+	return attrs
+}
+
+// fullURL 返回 url.full 属性值：去掉用户信息中的密码，默认将查询参数值替换为 REDACTED
+func fullURL(u *url.URL, rawQuery bool) string {
+	if rawQuery || u.RawQuery == "" {
+		return u.Redacted()
+	}
+	parts := strings.Split(u.RawQuery, "&")
+	for i, part := range parts {
+		if key, _, ok := strings.Cut(part, "="); ok {
+			parts[i] = key + "=REDACTED"
+		}
+	}
+	redacted := *u
+	redacted.RawQuery = strings.Join(parts, "&")
+	return redacted.Redacted()
+}
+
+func errorType(err error) string {
+	type timeout interface{ Timeout() bool }
+	if t, ok := err.(timeout); ok && t.Timeout() {
+		return "timeout"
+	}
+	return "error"
+}
+
+// countingBody 统计实际读取的响应体字节数，读到 EOF 或关闭时回调一次
+type countingBody struct {
+	io.ReadCloser
+	n      int64
+	once   sync.Once
+	onDone func(int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+func (b *countingBody) done() {
+	b.once.Do(func() { b.onDone(b.n) })
+}
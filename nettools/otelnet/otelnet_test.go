@@ -0,0 +1,114 @@
+package otelnet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coutcin-xw/goutils/nettools"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	var gotTraceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceparent = r.Header.Get("traceparent")
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	body, err := nettools.NewRequest().SetUrl(srv.URL).Post().
+		SetContext(ctx).
+		SetData(map[string]interface{}{"k": "v"}).
+		Use(Middleware(WithTracerProvider(tp), WithMeterProvider(mp))).
+		DoAndGetBody()
+	parent.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	client := spans[0]
+	if client.Name != http.MethodPost || client.SpanKind != trace.SpanKindClient {
+		t.Fatalf("unexpected span: %s %s", client.Name, client.SpanKind)
+	}
+	if client.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("client span is not a child of the parent span")
+	}
+	attrs := attribute.NewSet(client.Attributes...)
+	if v, _ := attrs.Value("http.response.status_code"); v.AsInt64() != 200 {
+		t.Fatalf("missing status attribute: %v", client.Attributes)
+	}
+	want := "00-" + client.SpanContext.TraceID().String() + "-" + client.SpanContext.SpanID().String() + "-01"
+	if gotTraceparent != want {
+		t.Fatalf("traceparent = %q, want %q", gotTraceparent, want)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				got[m.Name] = int64(data.DataPoints[0].Count)
+			case metricdata.Histogram[int64]:
+				got[m.Name] = data.DataPoints[0].Sum
+			}
+		}
+	}
+	if got["http.client.request.duration"] != 1 {
+		t.Fatalf("duration not recorded: %v", got)
+	}
+	if got["http.client.request.body.size"] != int64(len(`{"k":"v"}`)) {
+		t.Fatalf("request size not recorded: %v", got)
+	}
+	if got["http.client.response.body.size"] != int64(len("hello")) {
+		t.Fatalf("response size not recorded: %v", got)
+	}
+}
+
+func TestURLFullRedaction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{nil, srv.URL + "/sign?X-Amz-Signature=REDACTED&token=REDACTED"},
+		{[]Option{WithRawQuery()}, srv.URL + "/sign?X-Amz-Signature=abc&token=secret"},
+	} {
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		opts := append(tc.opts, WithTracerProvider(tp))
+		if _, err := nettools.NewRequest().SetUrl(srv.URL + "/sign?X-Amz-Signature=abc&token=secret").Get().
+			Use(Middleware(opts...)).DoAndGetBody(); err != nil {
+			t.Fatal(err)
+		}
+		attrs := attribute.NewSet(exporter.GetSpans()[0].Attributes...)
+		if v, _ := attrs.Value("url.full"); v.AsString() != tc.want {
+			t.Fatalf("url.full = %q, want %q", v.AsString(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package promnet 将 nettools 的请求指标适配为 Prometheus 指标
+package promnet
+
+import (
+	"github.com/coutcin-xw/goutils/nettools"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector 同时实现 nettools.MetricsCollector 与 prometheus.Collector，
+// 按 host/route 维度统计请求数、耗时、流量、重试和错误
+type Collector struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	bytesIn  *prometheus.CounterVec
+	bytesOut *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+// Options 配置 Collector
+type Options struct {
+	Namespace string    // 指标前缀，默认 "nettools"
+	Buckets   []float64 // 耗时直方图的桶（秒），默认 prometheus.DefBuckets
+}
+
+// NewCollector 创建 Prometheus 指标收集器，需自行注册到 Registry
+func NewCollector(opts Options) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "nettools"
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.DefBuckets
+	}
+
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "client_requests_total",
+			Help:      "Total number of HTTP client requests by status class.",
+		}, []string{"host", "route", "method", "status_class"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "client_request_duration_seconds",
+			Help:      "HTTP client request latency until response headers.",
+			Buckets:   opts.Buckets,
+		}, []string{"host", "route", "method"}),
+		bytesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "client_response_bytes_total",
+			Help:      "Total response body bytes read.",
+		}, []string{"host", "route"}),
+		bytesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "client_request_bytes_total",
+			Help:      "Total request body bytes sent.",
+		}, []string{"host", "route"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "client_retries_total",
+			Help:      "Total number of retried HTTP client requests.",
+		}, []string{"host", "route"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "client_errors_total",
+			Help:      "Total number of failed HTTP client requests by error kind.",
+		}, []string{"host", "route", "kind"}),
+	}
+}
+
+// ObserveRequest 实现 nettools.MetricsCollector
+func (c *Collector) ObserveRequest(m nettools.RequestMetrics) {
+	c.requests.WithLabelValues(m.Host, m.Route, m.Method, m.StatusClass).Inc()
+	c.duration.WithLabelValues(m.Host, m.Route, m.Method).Observe(m.Duration.Seconds())
+	c.bytesIn.WithLabelValues(m.Host, m.Route).Add(float64(m.BytesIn))
+	c.bytesOut.WithLabelValues(m.Host, m.Route).Add(float64(m.BytesOut))
+	if m.Retry {
+		c.retries.WithLabelValues(m.Host, m.Route).Inc()
+	}
+	if m.ErrorKind != "" {
+		c.errors.WithLabelValues(m.Host, m.Route, m.ErrorKind).Inc()
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.duration.Describe(ch)
+	c.bytesIn.Describe(ch)
+	c.bytesOut.Describe(ch)
+	c.retries.Describe(ch)
+	c.errors.Describe(ch)
+}
+
+// Collect 实现 prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.duration.Collect(ch)
+	c.bytesIn.Collect(ch)
+	c.bytesOut.Collect(ch)
+	c.retries.Collect(ch)
+	c.errors.Collect(ch)
+}
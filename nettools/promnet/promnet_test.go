@@ -0,0 +1,68 @@
+package promnet
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coutcin-xw/goutils/nettools"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	c := NewCollector(Options{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+
+	if _, err := nettools.NewRequest().SetUrl(srv.URL + "/users/1").Get().
+		SetMetrics(c).SetRoute("/users/{id}").DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	retry := nettools.WithRetryAttempt(context.Background(), 1)
+	resp, err := nettools.NewRequest().SetUrl(srv.URL + "/missing").Get().
+		SetContext(retry).SetMetrics(c).SetRoute("/missing").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := ln.Addr().String()
+	ln.Close()
+	if _, err := nettools.NewRequest().SetUrl("http://" + dead).Get().SetMetrics(c).Do(); err == nil {
+		t.Fatal("expected connection error")
+	}
+
+	if got := testutil.ToFloat64(c.requests.WithLabelValues(host, "/users/{id}", "GET", "2xx")); got != 1 {
+		t.Fatalf("2xx count = %v", got)
+	}
+	if got := testutil.ToFloat64(c.requests.WithLabelValues(host, "/missing", "GET", "4xx")); got != 1 {
+		t.Fatalf("4xx count = %v", got)
+	}
+	if got := testutil.ToFloat64(c.bytesIn.WithLabelValues(host, "/users/{id}")); got != 5 {
+		t.Fatalf("bytes in = %v", got)
+	}
+	if got := testutil.ToFloat64(c.retries.WithLabelValues(host, "/missing")); got != 1 {
+		t.Fatalf("retries = %v", got)
+	}
+	if got := testutil.ToFloat64(c.errors.WithLabelValues(dead, "", nettools.ErrorKindConnection)); got != 1 {
+		t.Fatalf("connection errors = %v", got)
+	}
+	if n := testutil.CollectAndCount(c, "nettools_client_request_duration_seconds"); n != 3 {
+		t.Fatalf("duration series = %d", n)
+	}
+}
@@ -0,0 +1,175 @@
+package nettools
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// HTTPVersion 表示请求使用的 HTTP 协议版本
+type HTTPVersion int
+
+const (
+	HTTPAuto  HTTPVersion = iota // HTTPS 通过 ALPN 协商 HTTP/2，不支持时回落 HTTP/1.1；HTTP 使用 HTTP/1.1
+	HTTP1                        // 只使用 HTTP/1.1
+	HTTP2                        // 强制 HTTP/2：HTTPS 未协商出 h2 时返回错误，HTTP 使用 h2c（先验知识方式）
+	HTTP3                        // 通过 Alt-Svc 发现 HTTP/3，QUIC 失败时回落 HTTP/2、HTTP/1.1；经过代理的请求不使用
+	HTTP3Only                    // HTTPS 请求直接使用 HTTP/3，不回落
+)
+
+func (v HTTPVersion) String() string {
+	switch v {
+	case HTTPAuto:
+		return "auto"
+	case HTTP1:
+		return "HTTP/1.1"
+	case HTTP2:
+		return "HTTP/2"
+	case HTTP3:
+		return "HTTP/3"
+	case HTTP3Only:
+		return "HTTP/3-only"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnPoolConfig 表示连接池参数，零值字段保持 Transport 原有设置
+type ConnPoolConfig struct {
+	MaxIdleConns        int           // 所有主机的最大空闲连接数
+	MaxIdleConnsPerHost int           // 每个主机的最大空闲连接数
+	MaxConnsPerHost     int           // 每个主机的最大连接数（含使用中的连接）
+	IdleConnTimeout     time.Duration // 空闲连接的保留时间
+	DisableKeepAlives   bool          // 关闭 HTTP keep-alive，每个请求使用新连接
+	KeepAlive           time.Duration // TCP keep-alive 探测间隔，<0 关闭；使用 SetDialContext 时不生效
+}
+
+// SetHTTPVersion 设置请求使用的 HTTP 协议版本
+func (r *Req) SetHTTPVersion(version HTTPVersion) *Req {
+	r.HTTPVersion = version
+	r.transportStale = true
+	return r
+}
+
+// SetConnPool 设置连接池参数
+func (r *Req) SetConnPool(config ConnPoolConfig) *Req {
+	r.ConnPool = &config
+	r.transportStale = true
+	return r
+}
+
+// GetProtocol 返回响应实际使用的协议，如 "HTTP/1.1"、"HTTP/2.0"、"HTTP/3.0"
+func GetProtocol(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return resp.Proto
+}
+
+// configureProtocol 在 Transport 上应用协议版本与连接池参数
+func (r *Req) configureProtocol(transport *http.Transport) {
+	if pool := r.ConnPool; pool != nil {
+		if pool.MaxIdleConns > 0 {
+			transport.MaxIdleConns = pool.MaxIdleConns
+		}
+		if pool.MaxIdleConnsPerHost > 0 {
+			transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+		}
+		if pool.MaxConnsPerHost > 0 {
+			transport.MaxConnsPerHost = pool.MaxConnsPerHost
+		}
+		if pool.IdleConnTimeout > 0 {
+			transport.IdleConnTimeout = pool.IdleConnTimeout
+		}
+		transport.DisableKeepAlives = pool.DisableKeepAlives
+	}
+
+	switch r.HTTPVersion {
+	case HTTP1:
+		// 非 nil 的空 TLSNextProto 会关闭内置的 HTTP/2；已启用过的 Transport 需要移除 h2
+		transport.ForceAttemptHTTP2 = false
+		if transport.TLSNextProto == nil {
+			transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		}
+		delete(transport.TLSNextProto, "h2")
+		if config := transport.TLSClientConfig; config != nil {
+			config.NextProtos = slices.DeleteFunc(config.NextProtos, func(proto string) bool { return proto == "h2" })
+		}
+	default:
+		// 设置了 TLSClientConfig 或 DialContext 后 net/http 默认不再尝试 HTTP/2
+		transport.ForceAttemptHTTP2 = true
+	}
+}
+
+// http2Transport 实现强制 HTTP/2：HTTPS 请求交给内层 Transport 并校验协商结果，HTTP 请求使用 h2c。
+// h2c 直接拨号，只经过 DialContext 中的代理链与代理池的 SOCKS5 代理，Transport.Proxy 选中代理时返回错误
+type http2Transport struct {
+	next  http.RoundTripper
+	h2c   *http2.Transport
+	proxy func(*http.Request) (*url.URL, error)
+}
+
+func newHTTP2Transport(next http.RoundTripper, inner *http.Transport) *http2Transport {
+	dial := inner.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	return &http2Transport{
+		next:  next,
+		proxy: inner.Proxy,
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+			IdleConnTimeout:    inner.IdleConnTimeout,
+			DisableCompression: inner.DisableCompression,
+		},
+	}
+}
+
+func (t *http2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// WebSocket 握手只能使用 HTTP/1.1
+	if isWebSocketUpgrade(req) {
+		return t.next.RoundTrip(req)
+	}
+	if req.URL.Scheme == "http" {
+		if t.proxy != nil {
+			proxyURL, err := t.proxy(req)
+			if err != nil {
+				return nil, err
+			}
+			if proxyURL != nil {
+				return nil, fmt.Errorf("h2c 请求无法经过代理 %s 发送，请使用 HTTPS 或不强制 HTTP/2", proxyURL.Redacted())
+			}
+		}
+		return t.h2c.RoundTrip(req)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("服务器 %s 未协商 HTTP/2，实际协议: %s", req.URL.Host, resp.Proto)
+	}
+	return resp, nil
+}
+
+func (t *http2Transport) Unwrap() http.RoundTripper {
+	return t.next
+}
+
+func (t *http2Transport) CloseIdleConnections() {
+	t.h2c.CloseIdleConnections()
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
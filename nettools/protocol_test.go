@@ -0,0 +1,175 @@
+package nettools
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// protoHandler 以响应体返回请求使用的协议
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Proto))
+})
+
+func TestHTTPVersionNegotiation(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, protoHandler, withHTTP2)
+
+	resp, err := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if GetProtocol(resp) != "HTTP/2.0" {
+		t.Fatalf("auto: expected HTTP/2.0 with custom TLS, got %s", GetProtocol(resp))
+	}
+
+	resp, err = NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM).SetHTTPVersion(HTTP1).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if GetProtocol(resp) != "HTTP/1.1" {
+		t.Fatalf("HTTP1: got %s", GetProtocol(resp))
+	}
+
+	resp, err = NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM).SetHTTPVersion(HTTP2).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if GetProtocol(resp) != "HTTP/2.0" {
+		t.Fatalf("HTTP2: got %s", GetProtocol(resp))
+	}
+}
+
+func TestHTTPVersionSwitch(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, protoHandler, withHTTP2)
+
+	req := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM)
+	if body, err := req.DoAndGetBody(); err != nil || string(body) != "HTTP/2.0" {
+		t.Fatalf("auto: %q %v", body, err)
+	}
+	// 已经启用过 HTTP/2 的 Transport 切换到 HTTP/1.1
+	if body, err := req.SetHTTPVersion(HTTP1).DoAndGetBody(); err != nil || string(body) != "HTTP/1.1" {
+		t.Fatalf("switch to HTTP1: %q %v", body, err)
+	}
+}
+
+func TestForceHTTP2Unsupported(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, protoHandler)
+
+	if _, err := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM).SetHTTPVersion(HTTP2).Do(); err == nil {
+		t.Fatal("expected error when server does not negotiate HTTP/2")
+	}
+	if body, err := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM).DoAndGetBody(); err != nil || string(body) != "HTTP/1.1" {
+		t.Fatalf("auto fallback: %q %v", body, err)
+	}
+}
+
+func TestH2C(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	srv := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer srv.Close()
+
+	req := NewRequest().SetUrl(srv.URL).Get().SetHTTPVersion(HTTP2)
+	for i := 0; i < 2; i++ {
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if GetProtocol(resp) != "HTTP/2.0" {
+			t.Fatalf("h2c: got %s", GetProtocol(resp))
+		}
+	}
+
+	if body, err := NewRequest().SetUrl(srv.URL).Get().DoAndGetBody(); err != nil || string(body) != "HTTP/1.1" {
+		t.Fatalf("plain http: %q %v", body, err)
+	}
+}
+
+func TestH2CWithProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	srv := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer srv.Close()
+
+	// Transport.Proxy 选中的代理 h2c 无法使用，直接报错而不是绕过代理
+	var proxyHits atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyHits.Add(1)
+	}))
+	defer proxy.Close()
+	_, err := NewRequest().SetUrl(srv.URL).Get().SetHTTPVersion(HTTP2).SetProxy(proxy.URL).Do()
+	if err == nil || !strings.Contains(err.Error(), "h2c") {
+		t.Fatalf("expected h2c proxy error, got %v", err)
+	}
+	if proxyHits.Load() != 0 {
+		t.Fatal("h2c request reached the HTTP proxy")
+	}
+
+	// 代理链在拨号时生效，h2c 同样经过代理
+	socks, hits := startSocks5Server(t, "", "")
+	body, err := NewRequest().SetUrl(srv.URL).Get().SetHTTPVersion(HTTP2).
+		SetProxyChain("socks5://"+socks, "socks5://"+socks).DoAndGetBody()
+	if err != nil || string(body) != "HTTP/2.0" {
+		t.Fatalf("h2c via proxy chain: %q %v", body, err)
+	}
+	if atomic.LoadInt32(hits) != 2 {
+		t.Fatalf("expected 2 SOCKS5 hops, got %d", atomic.LoadInt32(hits))
+	}
+}
+
+func TestConnPool(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	req := NewRequest().SetUrl(srv.URL).Get().SetConnPool(ConnPoolConfig{
+		MaxIdleConns:    7,
+		MaxConnsPerHost: 3,
+		IdleConnTimeout: time.Minute,
+		KeepAlive:       15 * time.Second,
+	})
+	for i := 0; i < 3; i++ {
+		if _, err := req.DoAndGetBody(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if conns.Load() != 1 {
+		t.Fatalf("expected connection reuse, got %d connections", conns.Load())
+	}
+	inner := innermostTransport(req.Client.Transport)
+	if inner.MaxIdleConns != 7 || inner.MaxConnsPerHost != 3 || inner.IdleConnTimeout != time.Minute || inner.DialContext == nil {
+		t.Fatal("pool config not applied")
+	}
+
+	conns.Store(0)
+	req.SetConnPool(ConnPoolConfig{DisableKeepAlives: true})
+	for i := 0; i < 3; i++ {
+		if _, err := req.DoAndGetBody(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if conns.Load() != 3 {
+		t.Fatalf("expected a new connection per request, got %d", conns.Load())
+	}
+}
@@ -0,0 +1,573 @@
+package nettools
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	defaultProxyMaxFails = 3
+	defaultProxyCooldown = 30 * time.Second
+)
+
+func (r *Req) SetProxyChain(proxyURLs ...string) *Req {
+	r.ProxyChain = proxyURLs
+	r.transportStale = true
+	return r
+}
+
+func (r *Req) SetProxyHeader(key, value string) *Req {
+	if r.ProxyHeaders == nil {
+		r.ProxyHeaders = make(map[string]string)
+	}
+	r.ProxyHeaders[key] = value
+	r.transportStale = true
+	return r
+}
+
+func (r *Req) SetProxyPool(pool *ProxyPool) *Req {
+	r.ProxyPool = pool
+	r.transportStale = true
+	return r
+}
+
+func (r *Req) SetProxyFromEnv(enable bool) *Req {
+	r.ProxyFromEnv = enable
+	r.transportStale = true
+	return r
+}
+
+func (r *Req) SetNoProxy(hosts ...string) *Req {
+	r.NoProxy = hosts
+	r.transportStale = true
+	return r
+}
+
+// configureProxy 按 代理池 > 代理链/单个代理 > 环境变量 的优先级配置代理
+func (r *Req) configureProxy(transport *http.Transport, direct hopDialer) error {
+	var header http.Header
+	if len(r.ProxyHeaders) > 0 {
+		header = make(http.Header)
+		for k, v := range r.ProxyHeaders {
+			header.Set(k, v)
+		}
+		transport.ProxyConnectHeader = header
+	}
+
+	chain := r.ProxyChain
+	if len(chain) == 0 && r.Proxy != "" {
+		chain = []string{r.Proxy}
+	}
+
+	switch {
+	case r.ProxyPool != nil:
+		transport.Proxy = withNoProxy(r.ProxyPool.proxyFunc, r.NoProxy)
+		// net/http 总是由 SOCKS5 代理解析域名，socks5 代理改为在拨号时按选中的代理本地解析后连接
+		transport.DialContext = (&poolDialer{direct: direct, opts: r.proxyDialOptions(header, transport.TLSClientConfig)}).DialContext
+	case len(chain) > 0:
+		proxies := make([]*url.URL, 0, len(chain))
+		for _, raw := range chain {
+			proxyURL, err := url.Parse(raw)
+			if err != nil {
+				return fmt.Errorf("解析代理URL失败: %w", err)
+			}
+			proxies = append(proxies, proxyURL)
+		}
+
+		// 单个 HTTP/HTTPS/SOCKS5h 代理直接交给 Transport 处理
+		if len(proxies) == 1 && proxies[0].Scheme != "socks5" {
+			transport.Proxy = withNoProxy(http.ProxyURL(proxies[0]), r.NoProxy)
+			return nil
+		}
+
+		dialer, err := newProxyChainDialer(proxies, direct, r.proxyDialOptions(header, transport.TLSClientConfig))
+		if err != nil {
+			return err
+		}
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+	case r.ProxyFromEnv:
+		transport.Proxy = ProxyFromEnvironment(r.NoProxy...)
+	}
+
+	return nil
+}
+
+// ProxyFromEnvironment 根据 HTTP_PROXY/HTTPS_PROXY/ALL_PROXY 环境变量选择代理，
+// NO_PROXY 与额外传入的 noProxy 中的 IP/CIDR 按 IsIPInList 规则匹配，域名按后缀匹配
+func ProxyFromEnvironment(noProxy ...string) func(*http.Request) (*url.URL, error) {
+	httpProxy := getEnvAny("HTTP_PROXY", "http_proxy")
+	httpsProxy := getEnvAny("HTTPS_PROXY", "https_proxy")
+	allProxy := getEnvAny("ALL_PROXY", "all_proxy")
+	bypass := append(strings.Split(getEnvAny("NO_PROXY", "no_proxy"), ","), noProxy...)
+
+	return func(req *http.Request) (*url.URL, error) {
+		if MatchNoProxy(req.URL.Host, bypass) {
+			return nil, nil
+		}
+
+		raw := httpProxy
+		if req.URL.Scheme == "https" {
+			raw = httpsProxy
+		}
+		if raw == "" {
+			raw = allProxy
+		}
+		if raw == "" {
+			return nil, nil
+		}
+
+		proxyURL, err := url.Parse(raw)
+		if err != nil || proxyURL.Host == "" {
+			// 兼容 "127.0.0.1:8080" 这类省略协议的写法
+			if proxyURL, err = url.Parse("http://" + raw); err != nil {
+				return nil, fmt.Errorf("解析代理URL失败: %w", err)
+			}
+		}
+		return proxyURL, nil
+	}
+}
+
+// MatchNoProxy 判断 host（可带端口）是否命中 NO_PROXY 列表
+func MatchNoProxy(hostport string, noProxy []string) bool {
+	host, port := hostport, ""
+	if h, p, err := net.SplitHostPort(hostport); err == nil {
+		host, port = h, p
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	isIP := net.ParseIP(host) != nil
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		// 带端口的条目要求端口一致
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+
+		if isIP {
+			if IsIPInList(host, []string{strings.Trim(entry, "[]")}) {
+				return true
+			}
+			continue
+		}
+
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+func withNoProxy(fn func(*http.Request) (*url.URL, error), noProxy []string) func(*http.Request) (*url.URL, error) {
+	if len(noProxy) == 0 {
+		return fn
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if MatchNoProxy(req.URL.Host, noProxy) {
+			return nil, nil
+		}
+		return fn(req)
+	}
+}
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// 代理链 ---------------------------------------------------
+
+// hopDialer 同时支持 Dial 与 DialContext，便于作为下一跳代理的 forward
+type hopDialer interface {
+	proxy.Dialer
+	proxy.ContextDialer
+}
+
+type proxyChainDialer struct {
+	direct  hopDialer
+	dialer  hopDialer
+	noProxy []string
+}
+
+// proxyDialOptions 表示代理拨号使用的 Req 配置
+type proxyDialOptions struct {
+	header   http.Header // CONNECT 请求头
+	noProxy  []string
+	tls      *tls.Config // https 代理握手的基础配置
+	resolver *Resolver   // socks5 本地解析使用的解析器，为空时使用系统解析
+}
+
+func (r *Req) proxyDialOptions(header http.Header, tlsConfig *tls.Config) proxyDialOptions {
+	return proxyDialOptions{header: header, noProxy: r.NoProxy, tls: tlsConfig, resolver: r.Resolver}
+}
+
+// newProxyChainDialer 构建按顺序穿过每个代理的拨号器，
+// 支持 socks5（本地解析）、socks5h（代理端解析）以及 http/https CONNECT 代理；
+// https 代理的 TLS 握手沿用 Req 的根证书、客户端证书与校验设置
+func newProxyChainDialer(proxies []*url.URL, direct hopDialer, opts proxyDialOptions) (*proxyChainDialer, error) {
+	dialer := direct
+	for _, proxyURL := range proxies {
+		switch proxyURL.Scheme {
+		case "socks5", "socks5h":
+			var auth *proxy.Auth
+			if proxyURL.User != nil {
+				password, _ := proxyURL.User.Password()
+				auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+			}
+			d, err := proxy.SOCKS5("tcp", proxyAddr(proxyURL), auth, dialer)
+			if err != nil {
+				return nil, fmt.Errorf("创建SOCKS5代理失败: %w", err)
+			}
+			dialer = d.(hopDialer)
+			if proxyURL.Scheme == "socks5" {
+				dialer = &localResolveDialer{forward: dialer, resolver: opts.resolver}
+			}
+		case "http", "https":
+			dialer = &connectDialer{proxy: proxyURL, forward: dialer, header: opts.header, tls: opts.tls}
+		default:
+			return nil, fmt.Errorf("不支持的代理协议: %s", proxyURL.Scheme)
+		}
+	}
+
+	return &proxyChainDialer{direct: direct, dialer: dialer, noProxy: opts.noProxy}, nil
+}
+
+func (d *proxyChainDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if MatchNoProxy(addr, d.noProxy) {
+		return d.direct.DialContext(ctx, network, addr)
+	}
+	return d.dialer.DialContext(ctx, network, addr)
+}
+
+// localResolveDialer 在本地解析目标域名后再交给 socks5 代理，resolver 为空时使用系统解析
+type localResolveDialer struct {
+	forward  hopDialer
+	resolver *Resolver
+}
+
+func (d *localResolveDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *localResolveDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) == nil {
+		var ips []net.IP
+		if d.resolver != nil {
+			ips, err = d.resolver.LookupIP(ctx, host)
+		} else {
+			ips, err = net.DefaultResolver.LookupIP(ctx, "ip", host)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("解析域名失败: %s", host)
+		}
+		addr = net.JoinHostPort(ips[0].String(), port)
+	}
+	return d.forward.DialContext(ctx, network, addr)
+}
+
+// connectDialer 通过 HTTP CONNECT 建立隧道，tls 为 https 代理握手使用的基础配置
+type connectDialer struct {
+	proxy   *url.URL
+	forward hopDialer
+	header  http.Header
+	tls     *tls.Config
+}
+
+func (d *connectDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", proxyAddr(d.proxy))
+	if err != nil {
+		return nil, err
+	}
+
+	if d.proxy.Scheme == "https" {
+		// 与 net/http 处理单个 https 代理相同，沿用目标请求的 TLS 配置，只替换 ServerName
+		config := &tls.Config{}
+		if d.tls != nil {
+			config = d.tls.Clone()
+		}
+		config.ServerName = d.proxy.Hostname()
+		config.NextProtos = nil
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("代理TLS握手失败: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	header := d.header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if d.proxy.User != nil {
+		password, _ := d.proxy.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(d.proxy.User.Username() + ":" + password))
+		header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: header,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("发送CONNECT请求失败: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("读取CONNECT响应失败: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("代理CONNECT失败: %s", resp.Status)
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// 代理池 ---------------------------------------------------
+
+// ProxyPool 表示轮询使用的代理池，连续失败的代理会在冷却期内被跳过
+type ProxyPool struct {
+	MaxFails int           // 连续失败多少次后暂时剔除，<=0 时为 3
+	Cooldown time.Duration // 剔除后的冷却时间，<=0 时为 30s
+
+	mu      sync.Mutex
+	proxies []*proxyState
+	next    int
+}
+
+type proxyState struct {
+	url           *url.URL
+	failures      int
+	successes     int
+	lastError     string
+	disabledUntil time.Time
+}
+
+// ProxyStatus 表示代理池中单个代理的健康状态
+type ProxyStatus struct {
+	URL           string
+	Healthy       bool
+	Failures      int // 连续失败次数
+	Successes     int
+	LastError     string
+	DisabledUntil time.Time
+}
+
+type proxyPickKey struct{}
+
+// proxyPick 记录一次请求实际使用的代理，用于回报健康状态
+type proxyPick struct {
+	url *url.URL
+}
+
+// NewProxyPool 创建代理池，支持 http/https/socks5/socks5h 代理
+func NewProxyPool(proxyURLs ...string) (*ProxyPool, error) {
+	if len(proxyURLs) == 0 {
+		return nil, fmt.Errorf("代理池不能为空")
+	}
+	pool := &ProxyPool{}
+	for _, raw := range proxyURLs {
+		proxyURL, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("解析代理URL失败: %w", err)
+		}
+		pool.proxies = append(pool.proxies, &proxyState{url: proxyURL})
+	}
+	return pool, nil
+}
+
+// Next 轮询返回下一个健康的代理
+func (p *ProxyPool) Next() (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(p.proxies); i++ {
+		state := p.proxies[(p.next+i)%len(p.proxies)]
+		if now.Before(state.disabledUntil) {
+			continue
+		}
+		p.next = (p.next + i + 1) % len(p.proxies)
+		return state.url, nil
+	}
+	return nil, fmt.Errorf("没有可用的代理")
+}
+
+// Report 回报代理的使用结果，err 为空表示成功
+func (p *ProxyPool) Report(proxyURL *url.URL, err error) {
+	if proxyURL == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, state := range p.proxies {
+		if state.url.String() != proxyURL.String() {
+			continue
+		}
+		if err == nil {
+			state.failures = 0
+			state.successes++
+			return
+		}
+		state.failures++
+		state.lastError = err.Error()
+		if state.failures >= p.maxFails() {
+			state.disabledUntil = time.Now().Add(p.cooldown())
+		}
+		return
+	}
+}
+
+// Status 返回所有代理的健康状态
+func (p *ProxyPool) Status() []ProxyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	status := make([]ProxyStatus, 0, len(p.proxies))
+	for _, state := range p.proxies {
+		status = append(status, ProxyStatus{
+			URL:           state.url.Redacted(),
+			Healthy:       !now.Before(state.disabledUntil),
+			Failures:      state.failures,
+			Successes:     state.successes,
+			LastError:     state.lastError,
+			DisabledUntil: state.disabledUntil,
+		})
+	}
+	return status
+}
+
+func (p *ProxyPool) maxFails() int {
+	if p.MaxFails <= 0 {
+		return defaultProxyMaxFails
+	}
+	return p.MaxFails
+}
+
+func (p *ProxyPool) cooldown() time.Duration {
+	if p.Cooldown <= 0 {
+		return defaultProxyCooldown
+	}
+	return p.Cooldown
+}
+
+// proxyFunc 为 Transport.Proxy 选择代理；socks5 代理需要本地解析，
+// 返回 nil 交由 poolDialer 按记录的选择结果拨号
+func (p *ProxyPool) proxyFunc(req *http.Request) (*url.URL, error) {
+	proxyURL, err := p.Next()
+	if err != nil {
+		return nil, err
+	}
+	if pick, ok := req.Context().Value(proxyPickKey{}).(*proxyPick); ok {
+		pick.url = proxyURL
+	}
+	if proxyURL.Scheme == "socks5" {
+		return nil, nil
+	}
+	return proxyURL, nil
+}
+
+// poolDialer 对代理池选中 socks5 代理的请求，按与代理链相同的规则本地解析后经代理连接。
+// 这些连接在连接池中按直连归类，空闲连接可能被之后选中其他 socks5 代理的请求复用
+type poolDialer struct {
+	direct hopDialer
+	opts   proxyDialOptions
+}
+
+func (d *poolDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	pick, ok := ctx.Value(proxyPickKey{}).(*proxyPick)
+	if !ok || pick.url == nil || pick.url.Scheme != "socks5" {
+		return d.direct.DialContext(ctx, network, addr)
+	}
+	dialer, err := newProxyChainDialer([]*url.URL{pick.url}, d.direct, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	return dialer.dialer.DialContext(ctx, network, addr)
+}
+
+// report 根据请求结果更新所用代理的健康状态，407 视为代理失败
+func (p *ProxyPool) report(pick *proxyPick, resp *http.Response, err error) {
+	if err == nil && resp != nil && resp.StatusCode == http.StatusProxyAuthRequired {
+		err = fmt.Errorf("代理认证失败: %s", resp.Status)
+	}
+	p.Report(pick.url, err)
+}
@@ -0,0 +1,312 @@
+package nettools
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// startSocks5Server 启动一个只支持 CONNECT 的最小 SOCKS5 服务端
+func startSocks5Server(t *testing.T, user, pass string) (string, *int32) {
+	addr, hits, _ := startRecordingSocks5Server(t, user, pass)
+	return addr, hits
+}
+
+// startRecordingSocks5Server 同 startSocks5Server，并记录最近一次 CONNECT 的目标主机；
+// 目标为 .test 域名时由代理端解析为 127.0.0.1
+func startRecordingSocks5Server(t *testing.T, user, pass string) (string, *int32, *atomic.Value) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var hits int32
+	var target atomic.Value
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSocks5(conn, user, pass, &hits, &target)
+		}
+	}()
+	return ln.Addr().String(), &hits, &target
+}
+
+func serveSocks5(conn net.Conn, user, pass string, hits *int32, seen *atomic.Value) error {
+	defer conn.Close()
+
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	if user != "" {
+		conn.Write([]byte{5, 2})
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return err
+		}
+		u := make([]byte, buf[1])
+		io.ReadFull(conn, u)
+		io.ReadFull(conn, buf[:1])
+		p := make([]byte, buf[0])
+		io.ReadFull(conn, p)
+		if string(u) != user || string(p) != pass {
+			conn.Write([]byte{1, 1})
+			return fmt.Errorf("auth failed")
+		}
+		conn.Write([]byte{1, 0})
+	} else {
+		conn.Write([]byte{5, 0})
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return err
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		l := make([]byte, 1)
+		io.ReadFull(conn, l)
+		name := make([]byte, l[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	case 4:
+		ip := make([]byte, 16)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	}
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+	seen.Store(host)
+	if strings.HasSuffix(host, ".test") {
+		host = "127.0.0.1"
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return err
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	atomic.AddInt32(hits, 1)
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+	return nil
+}
+
+// startConnectProxy 启动一个 HTTP CONNECT 代理并记录收到的 X-Proxy-Token
+func startConnectProxy(t *testing.T) (*httptest.Server, *atomic.Value) {
+	var token atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT", http.StatusMethodNotAllowed)
+			return
+		}
+		token.Store(r.Header.Get("X-Proxy-Token"))
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			defer target.Close()
+			io.Copy(target, conn)
+		}()
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, target)
+		}()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &token
+}
+
+func TestSocks5Proxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	addr, hits := startSocks5Server(t, "user", "pass")
+
+	for _, scheme := range []string{"socks5", "socks5h"} {
+		body, err := NewRequest().SetUrl(target.URL).Get().
+			SetProxy(scheme + "://user:pass@" + addr).
+			DoAndGetBody()
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		if string(body) != "ok" {
+			t.Fatalf("%s: unexpected body %q", scheme, body)
+		}
+	}
+	if atomic.LoadInt32(hits) != 2 {
+		t.Fatalf("expected 2 proxied connections, got %d", atomic.LoadInt32(hits))
+	}
+
+	if _, err := NewRequest().SetUrl(target.URL).Get().
+		SetProxy("socks5h://user:wrong@" + addr).Do(); err == nil {
+		t.Fatal("expected auth failure")
+	}
+}
+
+func TestProxyChain(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	connectProxy, token := startConnectProxy(t)
+	socksAddr, hits := startSocks5Server(t, "", "")
+
+	body, err := NewRequest().SetUrl(target.URL).Get().
+		SetProxyChain(connectProxy.URL, "socks5h://"+socksAddr).
+		SetProxyHeader("X-Proxy-Token", "secret").
+		DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "ok" || atomic.LoadInt32(hits) != 1 {
+		t.Fatalf("chain not used: body=%q hits=%d", body, atomic.LoadInt32(hits))
+	}
+	if got, _ := token.Load().(string); got != "secret" {
+		t.Fatalf("CONNECT header not sent: %q", got)
+	}
+}
+
+func TestProxyPoolHealth(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	// 取一个已关闭的端口作为失效代理
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := ln.Addr().String()
+	ln.Close()
+	socksAddr, _ := startSocks5Server(t, "", "")
+
+	pool, err := NewProxyPool("socks5h://"+dead, "socks5h://"+socksAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.MaxFails = 1
+
+	if _, err := NewRequest().SetUrl(target.URL).Get().SetProxyPool(pool).Do(); err == nil {
+		t.Fatal("expected first request through dead proxy to fail")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := NewRequest().SetUrl(target.URL).Get().SetProxyPool(pool).DoAndGetBody(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status := pool.Status()
+	if status[0].Healthy || status[0].Failures != 1 {
+		t.Fatalf("dead proxy should be unhealthy: %+v", status[0])
+	}
+	if !status[1].Healthy || status[1].Successes != 3 {
+		t.Fatalf("live proxy should be healthy: %+v", status[1])
+	}
+}
+
+func TestSocks5Resolution(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+	url := "http://socks.test:" + port + "/"
+
+	// socks5 由本地 Resolver 解析，socks5h 把域名交给代理解析；代理池与代理链行为一致
+	resolver, _ := NewResolver()
+	resolver.AddHost("socks.test", "127.0.0.1")
+	addr, _, seen := startRecordingSocks5Server(t, "", "")
+	for _, tc := range []struct{ scheme, want string }{{"socks5", "127.0.0.1"}, {"socks5h", "socks.test"}} {
+		pool, _ := NewProxyPool(tc.scheme + "://" + addr)
+		for name, req := range map[string]*Req{
+			"proxy": NewRequest().SetProxy(tc.scheme + "://" + addr),
+			"chain": NewRequest().SetProxyChain(tc.scheme+"://"+addr, tc.scheme+"://"+addr),
+			"pool":  NewRequest().SetProxyPool(pool),
+		} {
+			seen.Store("")
+			body, err := req.SetResolver(resolver).SetUrl(url).Get().DoAndGetBody()
+			if err != nil || string(body) != "ok" {
+				t.Fatalf("%s %s: %q %v", tc.scheme, name, body, err)
+			}
+			if got := seen.Load(); got != tc.want {
+				t.Fatalf("%s %s: proxy was asked for %q, want %q", tc.scheme, name, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestHTTPSProxyUsesReqTLS(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	// 代理证书由 AddCACertPEM 添加的根证书签发，代理链中的 https 代理同样需要信任它
+	connect, _ := startConnectProxy(t)
+	connect.Close()
+	proxy, proxyPEM := newTLSServer(t, connect.Config.Handler)
+	socksAddr, _ := startSocks5Server(t, "", "")
+	chain := []string{proxy.URL, "socks5h://" + socksAddr}
+
+	if _, err := NewRequest().SetUrl(target.URL).Get().SetProxyChain(chain...).Do(); err == nil {
+		t.Fatal("expected untrusted proxy certificate to fail")
+	}
+	body, err := NewRequest().SetUrl(target.URL).Get().SetProxyChain(chain...).AddCACertPEM(proxyPEM).DoAndGetBody()
+	if err != nil || string(body) != "ok" {
+		t.Fatalf("https proxy with Req roots: %q %v", body, err)
+	}
+}
+
+func TestMatchNoProxy(t *testing.T) {
+	noProxy := []string{"10.0.0.0/8", "192.168.1.1", ".internal.com", "example.org:8080"}
+	cases := map[string]bool{
+		"10.1.2.3:443":       true,
+		"192.168.1.1":        true,
+		"192.168.1.2":        false,
+		"api.internal.com":   true,
+		"internal.com":       true,
+		"notinternal.com":    false,
+		"example.org:8080":   true,
+		"example.org:443":    false,
+		"public.example.com": false,
+	}
+	for host, want := range cases {
+		if got := MatchNoProxy(host, noProxy); got != want {
+			t.Errorf("MatchNoProxy(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package nettools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited 表示请求被客户端限流拒绝
+var ErrRateLimited = errors.New("触发客户端限流")
+
+// RateLimitError 表示非阻塞模式下被拒绝的请求，可用 errors.Is(err, ErrRateLimited) 判断
+type RateLimitError struct {
+	Host       string
+	RetryAfter time.Duration // 预计多久后可以再次发送
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: %s，%s 后重试", ErrRateLimited, e.Host, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+const (
+	defaultMaxRateHosts = 10000            // 自动创建的主机状态数上限
+	rateHostIdleTimeout = 10 * time.Minute // 超过该时长未使用的主机状态优先淘汰
+)
+
+// RateLimiter 表示令牌桶限流器，可在多个 Req 之间共享，
+// 同时支持全局、按主机和按路由模式的限流
+type RateLimiter struct {
+	mu       sync.Mutex
+	global   *rate.Limiter
+	perHost  *rateSpec                // 对未单独配置的主机生效的默认限速
+	hosts    map[string]*rate.Limiter // SetHost 显式配置的限流器，不会被淘汰
+	states   map[string]*hostState    // 按主机自动创建的状态，超出上限时淘汰
+	maxHosts int
+	routes   []*routeLimiter
+	failFast bool
+	adaptive bool
+}
+
+// hostState 表示访问过的主机的限流状态
+type hostState struct {
+	limiter  *rate.Limiter // SetPerHost 的默认限速
+	adaptive *rate.Limiter // 根据响应头计算的限速，与配置的限速同时生效，取较严格者
+	blocked  time.Time     // 根据响应头暂停发送直到该时间
+	lastUsed time.Time
+}
+
+type rateSpec struct {
+	limit rate.Limit
+	burst int
+}
+
+type routeLimiter struct {
+	pattern string
+	limiter *rate.Limiter
+}
+
+// NewRateLimiter 创建限流器，未配置任何限速时不限制
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		hosts:    make(map[string]*rate.Limiter),
+		states:   make(map[string]*hostState),
+		maxHosts: defaultMaxRateHosts,
+	}
+}
+
+// SetGlobal 设置所有请求共享的限速，rps 为每秒请求数
+func (l *RateLimiter) SetGlobal(rps float64, burst int) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global = rate.NewLimiter(rate.Limit(rps), normalizeBurst(burst))
+	return l
+}
+
+// SetPerHost 设置每个主机各自独立的默认限速
+func (l *RateLimiter) SetPerHost(rps float64, burst int) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.perHost = &rateSpec{limit: rate.Limit(rps), burst: normalizeBurst(burst)}
+	return l
+}
+
+// SetHost 单独设置某个主机（host 或 host:port，IPv6 地址可带方括号）的限速
+func (l *RateLimiter) SetHost(host string, rps float64, burst int) *RateLimiter {
+	host = strings.ToLower(host)
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hosts[host] = rate.NewLimiter(rate.Limit(rps), normalizeBurst(burst))
+	return l
+}
+
+// SetMaxHosts 设置自动创建的主机状态（SetPerHost 的限速、响应头调整的限速与暂停）的数量上限，
+// 超出时先淘汰长时间未使用的主机，被淘汰的主机再次访问时重新开始计数；<=0 时使用默认值 10000
+func (l *RateLimiter) SetMaxHosts(n int) *RateLimiter {
+	if n <= 0 {
+		n = defaultMaxRateHosts
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxHosts = n
+	return l
+}
+
+// SetRoute 按路由模式限速，模式使用 path.Match 语法；
+// 以 "/" 开头时匹配 URL 路径，否则匹配 "host/path"
+func (l *RateLimiter) SetRoute(pattern string, rps float64, burst int) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.routes = append(l.routes, &routeLimiter{
+		pattern: pattern,
+		limiter: rate.NewLimiter(rate.Limit(rps), normalizeBurst(burst)),
+	})
+	return l
+}
+
+// SetFailFast 开启后超出限速的请求立即返回 RateLimitError，而不是等待
+func (l *RateLimiter) SetFailFast(failFast bool) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failFast = failFast
+	return l
+}
+
+// SetAdaptive 开启后根据 RateLimit-*/X-RateLimit-*/Retry-After 响应头动态调整主机限速
+func (l *RateLimiter) SetAdaptive(adaptive bool) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.adaptive = adaptive
+	return l
+}
+
+func normalizeBurst(burst int) int {
+	if burst < 1 {
+		return 1
+	}
+	return burst
+}
+
+// Wait 为请求获取令牌，阻塞模式下等待直到可发送或 ctx 结束
+func (l *RateLimiter) Wait(ctx context.Context, req *http.Request) error {
+	host := strings.ToLower(req.URL.Host)
+	limiters, blockedUntil, failFast := l.limitersFor(host, req.URL.Host+req.URL.Path, req.URL.Path)
+
+	// 服务端要求暂停的主机
+	if delay := time.Until(blockedUntil); delay > 0 {
+		if failFast {
+			return &RateLimitError{Host: host, RetryAfter: delay}
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if failFast {
+		reservations := make([]*rate.Reservation, 0, len(limiters))
+		for _, limiter := range limiters {
+			reservation := limiter.Reserve()
+			if delay := reservation.Delay(); delay > 0 || !reservation.OK() {
+				reservation.Cancel()
+				for _, prev := range reservations {
+					prev.Cancel()
+				}
+				return &RateLimitError{Host: host, RetryAfter: delay}
+			}
+			reservations = append(reservations, reservation)
+		}
+		return nil
+	}
+
+	for _, limiter := range limiters {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *RateLimiter) limitersFor(host, hostPath, urlPath string) ([]*rate.Limiter, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var limiters []*rate.Limiter
+	if l.global != nil {
+		limiters = append(limiters, l.global)
+	}
+	for _, route := range l.routes {
+		target := hostPath
+		if strings.HasPrefix(route.pattern, "/") {
+			target = urlPath
+		}
+		if ok, _ := path.Match(route.pattern, target); ok {
+			limiters = append(limiters, route.limiter)
+		}
+	}
+
+	explicit := l.explicitLimiter(host)
+	if explicit != nil {
+		limiters = append(limiters, explicit)
+	}
+	var blocked time.Time
+	if state := l.hostState(host, explicit == nil && l.perHost != nil); state != nil {
+		if explicit == nil && l.perHost != nil && state.limiter == nil {
+			state.limiter = rate.NewLimiter(l.perHost.limit, l.perHost.burst)
+		}
+		if explicit == nil && state.limiter != nil {
+			limiters = append(limiters, state.limiter)
+		}
+		if state.adaptive != nil {
+			limiters = append(limiters, state.adaptive)
+		}
+		blocked = state.blocked
+	}
+	return limiters, blocked, l.failFast
+}
+
+// explicitLimiter 返回 SetHost 配置的限流器，先匹配 host:port，再匹配主机名
+func (l *RateLimiter) explicitLimiter(host string) *rate.Limiter {
+	if limiter, ok := l.hosts[host]; ok {
+		return limiter
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return l.hosts[hostname]
+	}
+	return nil
+}
+
+// hostState 返回主机状态并刷新使用时间，create 为 true 时不存在则创建
+func (l *RateLimiter) hostState(host string, create bool) *hostState {
+	now := time.Now()
+	state, ok := l.states[host]
+	if !ok {
+		if !create {
+			return nil
+		}
+		if len(l.states) >= l.maxHosts {
+			l.evict(now)
+		}
+		state = &hostState{}
+		l.states[host] = state
+	}
+	state.lastUsed = now
+	return state
+}
+
+// evict 淘汰空闲且未被暂停的主机，仍超出上限时按最久未使用的顺序淘汰到上限的 90%
+func (l *RateLimiter) evict(now time.Time) {
+	for host, state := range l.states {
+		if now.Sub(state.lastUsed) > rateHostIdleTimeout && !state.blocked.After(now) {
+			delete(l.states, host)
+		}
+	}
+	if len(l.states) < l.maxHosts {
+		return
+	}
+	hosts := make([]string, 0, len(l.states))
+	for host := range l.states {
+		hosts = append(hosts, host)
+	}
+	// 仍处于暂停期的主机最后淘汰
+	slices.SortFunc(hosts, func(a, b string) int {
+		sa, sb := l.states[a], l.states[b]
+		if pa, pb := sa.blocked.After(now), sb.blocked.After(now); pa != pb {
+			if pa {
+				return 1
+			}
+			return -1
+		}
+		return sa.lastUsed.Compare(sb.lastUsed)
+	})
+	for _, host := range hosts[:len(hosts)-l.maxHosts*9/10] {
+		delete(l.states, host)
+	}
+}
+
+// Update 根据响应头调整主机限速，仅在 SetAdaptive(true) 时生效；
+// 调整后的限速与 SetHost/SetPerHost 的配置同时生效，不会超过配置的速率
+func (l *RateLimiter) Update(resp *http.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.adaptive {
+		return
+	}
+
+	host := strings.ToLower(resp.Request.URL.Host)
+	now := time.Now()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			l.hostState(host, true).blocked = now.Add(wait)
+			return
+		}
+	}
+
+	remaining, okRemaining := headerInt(resp.Header, "RateLimit-Remaining", "X-RateLimit-Remaining")
+	reset, okReset := headerInt(resp.Header, "RateLimit-Reset", "X-RateLimit-Reset")
+	if !okRemaining || !okReset {
+		return
+	}
+
+	// 大于一年的值视为 Unix 时间戳，否则为剩余秒数
+	window := time.Duration(reset) * time.Second
+	if reset > 365*24*3600 {
+		window = time.Until(time.Unix(reset, 0))
+	}
+	if window <= 0 {
+		return
+	}
+
+	state := l.hostState(host, true)
+	if remaining <= 0 {
+		state.blocked = now.Add(window)
+		return
+	}
+	state.blocked = time.Time{}
+
+	// 将剩余额度平摊到重置窗口内
+	limit := rate.Limit(float64(remaining) / window.Seconds())
+	if state.adaptive == nil {
+		state.adaptive = rate.NewLimiter(limit, 1)
+	} else {
+		state.adaptive.SetLimit(limit)
+	}
+}
+
+func headerInt(header http.Header, keys ...string) (int64, bool) {
+	for _, key := range keys {
+		raw := header.Get(key)
+		if raw == "" {
+			continue
+		}
+		// 兼容 "100, 100;w=60" 这类带策略的写法，只取第一个数值
+		raw, _, _ = strings.Cut(raw, ",")
+		raw, _, _ = strings.Cut(raw, ";")
+		v, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err == nil {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return at.Sub(now), true
+	}
+	return 0, false
+}
+
+func (r *Req) SetRateLimiter(limiter *RateLimiter) *Req {
+	r.RateLimiter = limiter
+	return r
+}
+
+// rateLimitMiddleware 在发送前获取令牌，收到响应后按响应头调整限速
+func rateLimitMiddleware(limiter *RateLimiter) Middleware {
+	return func(next DoFunc) DoFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if err := limiter.Wait(req.Context(), req); err != nil {
+				return nil, err
+			}
+			resp, err := next(req)
+			if err == nil {
+				limiter.Update(resp)
+			}
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package nettools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/exhausted" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", "60")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	do := func(limiter *RateLimiter, path string, ctx context.Context) error {
+		_, err := NewRequest().SetUrl(srv.URL + path).Get().
+			SetContext(ctx).SetRateLimiter(limiter).DoAndGetBody()
+		return err
+	}
+	bg := context.Background()
+
+	// 按主机限速，非阻塞模式下第二个请求立即失败
+	limiter := NewRateLimiter().SetPerHost(0.1, 1).SetFailFast(true)
+	if err := do(limiter, "/", bg); err != nil {
+		t.Fatal(err)
+	}
+	err := do(limiter, "/", bg)
+	var rlErr *RateLimitError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &rlErr) || rlErr.RetryAfter <= 0 {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+
+	// 阻塞模式遵循 context 取消
+	limiter = NewRateLimiter().SetGlobal(0.1, 1)
+	if err := do(limiter, "/", bg); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(bg, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := do(limiter, "/", ctx); err == nil || time.Since(start) > time.Second {
+		t.Fatalf("expected prompt context error, got %v after %s", err, time.Since(start))
+	}
+
+	// 路由限速只影响匹配的路径
+	limiter = NewRateLimiter().SetRoute("/api/*", 0.1, 1).SetFailFast(true)
+	for _, path := range []string{"/api/a", "/other", "/other"} {
+		if err := do(limiter, path, bg); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	if err := do(limiter, "/api/b", bg); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected route limit, got %v", err)
+	}
+
+	// 根据响应头暂停发送
+	limiter = NewRateLimiter().SetAdaptive(true).SetFailFast(true)
+	if err := do(limiter, "/exhausted", bg); err != nil {
+		t.Fatal(err)
+	}
+	if err := do(limiter, "/", bg); !errors.As(err, &rlErr) || rlErr.RetryAfter < 50*time.Second {
+		t.Fatalf("expected host to be paused by headers, got %v", err)
+	}
+}
+
+func TestRateLimiterAdaptiveCap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "1000")
+		w.Header().Set("RateLimit-Reset", "1")
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// 响应头允许 1000 rps，但显式配置的 0.1 rps 仍然生效
+	host := httptest.NewRequest(http.MethodGet, srv.URL, nil).URL.Hostname()
+	limiter := NewRateLimiter().SetHost(host, 0.1, 1).SetAdaptive(true).SetFailFast(true)
+	req := NewRequest().SetUrl(srv.URL).Get().SetRateLimiter(limiter)
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := req.DoAndGetBody(); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("adaptive limit raised configured cap, got %v", err)
+	}
+}
+
+func TestRateLimiterIPv6Host(t *testing.T) {
+	limiter := NewRateLimiter().SetHost("::1", 0.1, 1).SetFailFast(true)
+	req := httptest.NewRequest(http.MethodGet, "http://[::1]:443/", nil)
+	if err := limiter.Wait(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Wait(context.Background(), req); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected [::1]:443 to match ::1, got %v", err)
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	limiter := NewRateLimiter().SetPerHost(100, 1).SetAdaptive(true).SetMaxHosts(10)
+	blocked := httptest.NewRequest(http.MethodGet, "http://blocked.test/", nil)
+	limiter.Update(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"60"}},
+		Request:    blocked,
+	})
+	for i := 0; i < 100; i++ {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://host%d.test/", i), nil)
+		if err := limiter.Wait(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	limiter.mu.Lock()
+	n, ok := len(limiter.states), limiter.states["blocked.test"] != nil
+	limiter.mu.Unlock()
+	if n > 10 || !ok {
+		t.Fatalf("expected at most 10 hosts with the paused one kept, got %d (paused kept: %v)", n, ok)
+	}
+}
@@ -0,0 +1,148 @@
+package nettools
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// 默认最大跳转次数。net/http 在第 10 个连续请求前停止（len(via) >= 10），即最多跳转 9 次
+const defaultMaxRedirects = 9
+
+// RedirectAuthMode 控制重定向时 Authorization 头的处理方式
+type RedirectAuthMode int
+
+const (
+	// RedirectAuthDefault 沿用 net/http 行为：跳转到非同域/子域时移除
+	RedirectAuthDefault RedirectAuthMode = iota
+	// RedirectAuthStrip 只要主机发生变化就移除
+	RedirectAuthStrip
+	// RedirectAuthKeep 每一跳都重新附加原始请求的 Authorization
+	RedirectAuthKeep
+)
+
+// RedirectPolicy 表示重定向策略
+type RedirectPolicy struct {
+	Disable        bool             // 不跟随重定向，直接返回 3xx 响应
+	MaxHops        int              // 最大跳转次数，<=0 时与 net/http 相同（9 次）
+	SameHostOnly   bool             // 只允许同主机跳转
+	PreserveMethod bool             // 301/302/303 也保留原请求方法和请求体（307/308 始终保留）
+	Auth           RedirectAuthMode // Authorization 头的处理方式
+}
+
+// RedirectHop 表示重定向链中的一跳
+type RedirectHop struct {
+	StatusCode int
+	URL        *url.URL // 返回该 3xx 响应的请求地址
+	Location   string
+	Header     http.Header
+}
+
+func (r *Req) SetRedirectPolicy(policy *RedirectPolicy) *Req {
+	r.Redirect = policy
+	return r
+}
+
+func (r *Req) DisableRedirects() *Req {
+	r.redirectPolicy().Disable = true
+	return r
+}
+
+func (r *Req) SetMaxRedirects(n int) *Req {
+	r.redirectPolicy().MaxHops = n
+	return r
+}
+
+func (r *Req) redirectPolicy() *RedirectPolicy {
+	if r.Redirect == nil {
+		r.Redirect = &RedirectPolicy{}
+	}
+	return r.Redirect
+}
+
+// checkRedirect 根据 RedirectPolicy 决定是否继续跟随重定向
+func (p *RedirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if p.Disable {
+		return http.ErrUseLastResponse
+	}
+
+	maxHops := p.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultMaxRedirects
+	}
+	if len(via) > maxHops {
+		return fmt.Errorf("重定向次数超过上限: %d", maxHops)
+	}
+
+	first := via[0]
+	if p.SameHostOnly && req.URL.Host != first.URL.Host {
+		return fmt.Errorf("禁止跨主机重定向: %s -> %s", first.URL.Host, req.URL.Host)
+	}
+
+	// 301/302/303 会被 net/http 改写为 GET 并丢弃请求体，这里按需恢复
+	if p.PreserveMethod {
+		if err := restoreRedirectBody(req, first); err != nil {
+			return err
+		}
+	}
+
+	switch p.Auth {
+	case RedirectAuthStrip:
+		if req.URL.Host != first.URL.Host {
+			req.Header.Del("Authorization")
+		}
+	case RedirectAuthKeep:
+		if auth := first.Header.Get("Authorization"); auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+	}
+
+	return nil
+}
+
+func restoreRedirectBody(req, prev *http.Request) error {
+	req.Method = prev.Method
+	if prev.GetBody == nil || (req.Body != nil && req.Body != http.NoBody) {
+		return nil
+	}
+	body, err := prev.GetBody()
+	if err != nil {
+		return fmt.Errorf("重放请求体失败: %w", err)
+	}
+	req.Body = body
+	req.GetBody = prev.GetBody
+	req.ContentLength = prev.ContentLength
+	if ct := prev.Header.Get("Content-Type"); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	return nil
+}
+
+// RedirectChain 返回得到该响应所经过的重定向链，按时间先后排列
+func RedirectChain(resp *http.Response) []RedirectHop {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+
+	var hops []RedirectHop
+	for prev := resp.Request.Response; prev != nil; {
+		hop := RedirectHop{
+			StatusCode: prev.StatusCode,
+			Location:   prev.Header.Get("Location"),
+			Header:     prev.Header.Clone(),
+		}
+		if prev.Request == nil {
+			hops = append(hops, hop)
+			break
+		}
+		hop.URL = prev.Request.URL
+		hops = append(hops, hop)
+		prev = prev.Request.Response
+	}
+
+	// 反转为从第一跳开始
+	for i, j := 0, len(hops)-1; i < j; i, j = i+1, j-1 {
+		hops[i], hops[j] = hops[j], hops[i]
+	}
+	return hops
+}
@@ -0,0 +1,104 @@
+package nettools
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + string(body)))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := NewRequest().SetUrl(srv.URL + "/a").Get().Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	chain := RedirectChain(resp)
+	if len(chain) != 2 || chain[0].StatusCode != http.StatusFound || chain[1].StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("unexpected chain: %+v", chain)
+	}
+	if chain[0].URL.Path != "/a" || chain[1].Location != "/c" {
+		t.Fatalf("unexpected hop info: %+v", chain)
+	}
+
+	resp, err = NewRequest().SetUrl(srv.URL + "/a").Get().DisableRedirects().Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected 302, got %d", resp.StatusCode)
+	}
+
+	if _, err = NewRequest().SetUrl(srv.URL + "/a").Get().SetMaxRedirects(1).Do(); err == nil {
+		t.Fatal("expected max redirects error")
+	}
+
+	body, err := NewRequest().SetUrl(srv.URL + "/a").Post().
+		SetData(map[string]interface{}{"k": "v"}).
+		SetRedirectPolicy(&RedirectPolicy{PreserveMethod: true}).
+		DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `POST {"k":"v"}` {
+		t.Fatalf("method/body not preserved: %q", body)
+	}
+}
+
+func TestRedirectDefaultLimit(t *testing.T) {
+	// /n 跳转到 /n-1，/0 返回 200
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/%d", &n)
+		if n == 0 {
+			w.Write([]byte("ok"))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/%d", n-1), http.StatusFound)
+	}))
+	defer srv.Close()
+
+	// 默认策略与 net/http 一致：9 次跳转成功，10 次失败
+	for _, req := range []*Req{NewRequest(), NewRequest().SetRedirectPolicy(&RedirectPolicy{})} {
+		if _, err := req.SetUrl(srv.URL + "/9").Get().DoAndGetBody(); err != nil {
+			t.Fatalf("9 hops: %v", err)
+		}
+		if _, err := req.SetUrl(srv.URL + "/10").Get().Do(); err == nil {
+			t.Fatal("expected 10 hops to fail")
+		}
+	}
+
+	// 清除策略后恢复默认行为
+	req := NewRequest().SetUrl(srv.URL + "/1").Get().DisableRedirects()
+	resp, err := req.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected 302, got %d", resp.StatusCode)
+	}
+	clone := req.Clone()
+	for _, r := range []*Req{req, clone} {
+		r.SetRedirectPolicy(nil)
+		if body, err := r.DoAndGetBody(); err != nil || string(body) != "ok" {
+			t.Fatalf("redirects not restored: %q %v", body, err)
+		}
+	}
+}
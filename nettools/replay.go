@@ -0,0 +1,176 @@
+package nettools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync/atomic"
+)
+
+const defaultSpillThreshold = 4 << 20
+
+// spillThreshold 为消息体保存在内存中的上限，超过后写入临时文件
+var spillThreshold atomic.Int64
+
+func init() {
+	spillThreshold.Store(defaultSpillThreshold)
+}
+
+// SetBodySpillThreshold 设置 Read* 函数在内存中缓存消息体的上限，默认 4MB，
+// 超过后转存到临时文件；<=0 表示始终保存在内存中
+func SetBodySpillThreshold(threshold int64) {
+	spillThreshold.Store(threshold)
+}
+
+// ReplayableBody 表示可重复读取的消息体，Read* 函数读取后会用它替换原消息体。
+// 本身作为 io.ReadSeekCloser 使用，也可以通过 NewReader 获取互不影响的读取器
+type ReplayableBody struct {
+	*io.SectionReader
+	buf     []byte
+	file    *os.File
+	size    int64
+	removed bool // 临时文件已在打开后删除，关闭时无需再删除
+}
+
+// NewReplayableBody 读取 r 的全部内容，sizeHint 为已知长度（未知时传 -1）；
+// limit > 0 时超出部分返回 BodyTooLargeError，此时返回值包含已读取的全部内容（最多 limit+1 字节）
+func NewReplayableBody(r io.Reader, sizeHint, limit int64) (*ReplayableBody, error) {
+	threshold := spillThreshold.Load()
+
+	// 已知长度且不需要转存时一次分配到位
+	capacity := int64(512)
+	if sizeHint > 0 && (threshold <= 0 || sizeHint <= threshold) {
+		capacity = sizeHint + 1 // 多留 1 字节，读到 EOF 时无需扩容
+	}
+	buf := make([]byte, 0, capacity)
+	for {
+		if len(buf) == cap(buf) {
+			if threshold > 0 && int64(len(buf)) >= threshold {
+				return spillBody(buf, r, limit)
+			}
+			buf = append(buf, 0)[:len(buf)]
+		}
+		p := buf[len(buf):cap(buf)]
+		// 超限判断只需多读 1 字节
+		if limit > 0 && int64(len(buf)+len(p)) > limit+1 {
+			p = p[:limit+1-int64(len(buf))]
+		}
+		n, err := r.Read(p)
+		buf = buf[:len(buf)+n]
+		if limit > 0 && int64(len(buf)) > limit {
+			return newMemoryBody(buf), &BodyTooLargeError{Limit: limit, ContentLength: -1}
+		}
+		if err == io.EOF {
+			return newMemoryBody(buf), nil
+		}
+		if err != nil {
+			return newMemoryBody(buf), err
+		}
+	}
+}
+
+func newMemoryBody(buf []byte) *ReplayableBody {
+	return &ReplayableBody{
+		SectionReader: io.NewSectionReader(bytes.NewReader(buf), 0, int64(len(buf))),
+		buf:           buf,
+		size:          int64(len(buf)),
+	}
+}
+
+// spillBody 将已读取的内容与剩余内容写入临时文件
+func spillBody(head []byte, rest io.Reader, limit int64) (*ReplayableBody, error) {
+	if limit > 0 {
+		rest = io.LimitReader(rest, limit+1-int64(len(head)))
+	}
+	file, err := os.CreateTemp("", "nettools-body-*")
+	if err != nil {
+		return newMemoryBody(head), fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	// 除 Windows 外打开的文件可以直接删除，内容在关闭前仍可读取，未调用 Close 时也不会遗留文件
+	removed := runtime.GOOS != "windows" && os.Remove(file.Name()) == nil
+	if _, err := file.Write(head); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return newMemoryBody(head), fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	n, err := io.Copy(file, rest)
+	body := &ReplayableBody{file: file, size: int64(len(head)) + n, removed: removed}
+	body.SectionReader = io.NewSectionReader(file, 0, body.size)
+	if err != nil {
+		return body, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if limit > 0 && body.size > limit {
+		return body, &BodyTooLargeError{Limit: limit, ContentLength: -1}
+	}
+	return body, nil
+}
+
+// NewReader 返回从头开始读取的独立读取器，可在多个协程中同时使用
+func (b *ReplayableBody) NewReader() *io.SectionReader {
+	if b.file != nil {
+		return io.NewSectionReader(b.file, 0, b.size)
+	}
+	return io.NewSectionReader(bytes.NewReader(b.buf), 0, b.size)
+}
+
+// Bytes 返回内存中的内容，不应修改；已转存到临时文件时返回 nil
+func (b *ReplayableBody) Bytes() []byte {
+	if b.file != nil {
+		return nil
+	}
+	return b.buf
+}
+
+// Len 返回消息体的总长度
+func (b *ReplayableBody) Len() int64 {
+	return b.size
+}
+
+// Spilled 表示内容是否已转存到临时文件
+func (b *ReplayableBody) Spilled() bool {
+	return b.file != nil
+}
+
+// Close 关闭并删除临时文件，内存中的内容在关闭后仍可读取
+func (b *ReplayableBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	if !b.removed {
+		os.Remove(b.file.Name())
+	}
+	return err
+}
+
+// bufferBody 将消息体读取为 ReplayableBody，已经是 ReplayableBody 时回到开头复用。
+// 读取失败时 restore 为拼接了已读内容与剩余内容的消息体
+func bufferBody(body io.ReadCloser, sizeHint, limit int64) (replay *ReplayableBody, restore io.ReadCloser, err error) {
+	if replay, ok := body.(*ReplayableBody); ok {
+		if limit > 0 && replay.size > limit {
+			return nil, body, &BodyTooLargeError{Limit: limit, ContentLength: replay.size}
+		}
+		replay.Seek(0, io.SeekStart)
+		return replay, nil, nil
+	}
+
+	replay, err = NewReplayableBody(body, sizeHint, limit)
+	if err != nil {
+		if replay.Spilled() {
+			// 临时文件在拼接后的消息体关闭时删除
+			return nil, &restoredBody{Reader: io.MultiReader(replay, body), Closer: closerFunc(func() error {
+				replay.Close()
+				return body.Close()
+			})}, err
+		}
+		return nil, restoreBody(replay, body), err
+	}
+	body.Close()
+	return replay, nil, nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
@@ -0,0 +1,126 @@
+package nettools
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReplayableBody(t *testing.T) {
+	payload := strings.Repeat("0123456789", 100)
+	for _, threshold := range []int64{0, 64} {
+		SetBodySpillThreshold(threshold)
+		resp := &http.Response{
+			Proto:         "HTTP/1.1",
+			StatusCode:    http.StatusOK,
+			Header:        make(http.Header),
+			Body:          io.NopCloser(strings.NewReader(payload)),
+			ContentLength: -1,
+		}
+
+		dump, err := ReadResponse(resp, true)
+		if err != nil || !strings.HasSuffix(string(dump), "\r\n\r\n"+payload[:100]) {
+			t.Fatalf("threshold %d: dump=%q err=%v", threshold, dump, err)
+		}
+		body, err := ReadResponseBody(resp)
+		if err != nil || string(body) != payload {
+			t.Fatalf("threshold %d: second read got %d bytes, err=%v", threshold, len(body), err)
+		}
+
+		replay := resp.Body.(*ReplayableBody)
+		if replay.Spilled() != (threshold > 0) {
+			t.Fatalf("threshold %d: spilled=%v", threshold, replay.Spilled())
+		}
+		// 多个读取器互不影响，可并发读取
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if got, _ := io.ReadAll(replay.NewReader()); string(got) != payload {
+					t.Errorf("threshold %d: independent reader got %d bytes", threshold, len(got))
+				}
+			}()
+		}
+		wg.Wait()
+		if got, _ := io.ReadAll(resp.Body); string(got) != payload {
+			t.Fatalf("threshold %d: resp.Body got %d bytes", threshold, len(got))
+		}
+
+		if replay.Spilled() {
+			name := replay.file.Name()
+			// 除 Windows 外临时文件在写入前已删除，不依赖 Close
+			if _, err := os.Stat(name); runtime.GOOS != "windows" && !os.IsNotExist(err) {
+				t.Fatalf("temp file %s still exists before Close", name)
+			}
+			resp.Body.Close()
+			if _, err := os.Stat(name); !os.IsNotExist(err) {
+				t.Fatalf("temp file %s not removed", name)
+			}
+		}
+	}
+	SetBodySpillThreshold(defaultSpillThreshold)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader(payload))
+	if _, err := ReadRequest(req, false); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		rc, _ := req.GetBody()
+		if got, _ := io.ReadAll(rc); string(got) != payload {
+			t.Fatalf("GetBody replay %d got %d bytes", i, len(got))
+		}
+	}
+}
+
+func benchmarkResponse(body []byte) *http.Response {
+	return &http.Response{
+		Proto:         "HTTP/1.1",
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+func BenchmarkReadResponse(b *testing.B) {
+	body := bytes.Repeat([]byte("a"), 256<<10)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadResponse(benchmarkResponse(body), false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadResponseBody(b *testing.B) {
+	body := bytes.Repeat([]byte("a"), 256<<10)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadResponseBody(benchmarkResponse(body)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadResponseBodySpill(b *testing.B) {
+	SetBodySpillThreshold(64 << 10)
+	defer SetBodySpillThreshold(defaultSpillThreshold)
+	body := bytes.Repeat([]byte("a"), 256<<10)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		resp := benchmarkResponse(body)
+		if _, err := ReadResponseBody(resp); err != nil {
+			b.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+}
@@ -2,8 +2,8 @@ package nettools
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,10 +11,11 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/coutcin-xw/go-logs"
 )
 
 // 链式方法集合
@@ -30,6 +31,8 @@ func (r *Req) Put() *Req    { return r.SetMethod(http.MethodPut) }
 func (r *Req) Delete() *Req { return r.SetMethod(http.MethodDelete) }
 
 func (r *Req) GetRequest() *http.Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.Requests
 }
 
@@ -66,23 +69,35 @@ func (r *Req) AddCookie(cookie *http.Cookie) *Req {
 	return r
 }
 
+// SetContext 设置请求的上下文，用于取消、超时以及链路追踪的父 Span
+func (r *Req) SetContext(ctx context.Context) *Req {
+	r.Ctx = ctx
+	return r
+}
+
 func (r *Req) SetTimeout(timeout time.Duration) *Req {
 	r.Client.Timeout = timeout
 	return r
 }
 
 func (r *Req) SetVerify(verify bool) *Req {
+	if !verify {
+		logs.Log.Warn("TLS证书校验已关闭，请求将无法识别中间人攻击")
+	}
 	r.Verify = verify
+	r.transportStale = true
 	return r
 }
 
 func (r *Req) SetCertPaths(paths []string) *Req {
 	r.CertPaths = paths
+	r.transportStale = true
 	return r
 }
 
 func (r *Req) SetProxy(proxyURL string) *Req {
 	r.Proxy = proxyURL
+	r.transportStale = true
 	return r
 }
 
@@ -118,35 +133,81 @@ func (r *Req) Do() (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
+	if body != nil && r.RequestEncoding != "" {
+		if body, err = compressBody(body, r.RequestEncoding); err != nil {
+			return nil, err
+		}
+	}
 
 	// 创建请求对象
-	req, err := http.NewRequest(r.Method, reqUrl, body)
+	req, err := http.NewRequestWithContext(r.context(), r.Method, reqUrl, body)
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	// 设置请求头
 	r.setHeaders(req, contentType)
+	if body != nil && r.RequestEncoding != "" && req.Header.Get("Content-Encoding") == "" {
+		req.Header.Set("Content-Encoding", r.RequestEncoding)
+	}
 
 	// 配置HTTP客户端
-	if err := r.configureClient(); err != nil {
+	r.mu.Lock()
+	err = r.configureClient()
+	r.mu.Unlock()
+	if err != nil {
 		return nil, err
 	}
+	// 附加耗时追踪
+	var trace *traceRecorder
+	if r.Trace {
+		trace = newTraceRecorder()
+		req = req.WithContext(trace.withContext(req.Context()))
+	}
+
+	// 记录代理池本次选中的代理
+	var pick *proxyPick
+	if r.ProxyPool != nil {
+		pick = &proxyPick{}
+		req = req.WithContext(context.WithValue(req.Context(), proxyPickKey{}, pick))
+	}
+
+	r.mu.Lock()
 	r.Requests = req
+	r.mu.Unlock()
 	// 执行请求
-	resp, err := r.Client.Do(req)
+	resp, err := r.send(req)
+	if pick != nil {
+		r.ProxyPool.report(pick, resp, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("请求执行失败: %w", err)
 	}
 
+	if trace != nil {
+		resp.Body = trace.wrapBody(resp.Body)
+	}
+
 	// 保存响应cookie
 	r.saveCookies(resp, req.URL)
 
+	// 限制响应体大小，Content-Length 超限时直接拒绝
+	if err := limitResponse(resp, r.MaxBodySize); err != nil {
+		return nil, err
+	}
+
 	return resp, nil
 }
 
 // 私有方法 ---------------------------------------------------
 
+func (r *Req) context() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+	return r.Ctx
+}
+
 func (r *Req) validate() error {
 	if r.Url == "" {
 		return fmt.Errorf("请求URL不能为空")
@@ -251,57 +312,88 @@ func (r *Req) setHeaders(req *http.Request, contentType string) {
 	}
 }
 
+// configureClient 组装 Transport，只在首次请求、配置变化或 Client.Transport 被替换时执行，
+// 调用方需持有 r.mu；Client 只在这里修改，其他协程中进行的请求不会读到修改中的状态
 func (r *Req) configureClient() error {
-	// 确保Transport存在
-	if r.Client.Transport == nil {
-		r.Client.Transport = &http.Transport{}
+	if r.tlsErr != nil {
+		return r.tlsErr
 	}
-
-	// 类型断言获取Transport
-	transport, ok := r.Client.Transport.(*http.Transport)
-	if !ok {
-		return fmt.Errorf("不支持的Transport类型")
+	if r.cloneErr != nil {
+		return r.cloneErr
 	}
 
-	// 配置TLS
-	if transport.TLSClientConfig == nil {
-		transport.TLSClientConfig = &tls.Config{}
+	// 配置重定向策略
+	if r.Redirect != r.appliedRedirect {
+		if r.Redirect != nil {
+			r.Client.CheckRedirect = r.Redirect.checkRedirect
+		} else {
+			// 清除策略后恢复 http.Client 默认的重定向行为
+			r.Client.CheckRedirect = nil
+		}
+		r.appliedRedirect = r.Redirect
 	}
-	transport.TLSClientConfig.InsecureSkipVerify = !r.Verify
 
-	// 在证书配置部分修改为：
-	if len(r.CertPaths) > 0 {
-		// 使用系统证书池作为基础
-		pool, err := x509.SystemCertPool()
-		if err != nil || pool == nil {
-			pool = x509.NewCertPool()
+	// 已组装过的 Transport 取回调用方提供的原始 Transport，否则以当前值为基础
+	var base http.RoundTripper
+	if layered, ok := r.Client.Transport.(*layeredTransport); ok && layered.owner == r {
+		// Set 方法会标记配置已修改，直接修改字段时与上次组装的配置比较
+		if !r.transportStale && r.appliedConfig != nil && r.appliedConfig.matches(r) {
+			return nil
 		}
-
-		// 添加自定义证书
-		for _, path := range r.CertPaths {
-			cert, err := os.ReadFile(path)
-			if err != nil {
-				return fmt.Errorf("读取证书失败: %w", err)
-			}
-			if !pool.AppendCertsFromPEM(cert) {
-				return fmt.Errorf("添加证书到池失败: %s", path)
+		base = layered.base
+		if layered.shared.Load() {
+			// 基础 Transport 与克隆出的 Req 共用，复制后再修改，互不影响
+			var err error
+			if base, err = cloneTransport(base); err != nil {
+				return err
 			}
+			r.insecureTransport = nil
+		} else {
+			// 连接池中的连接沿用旧的 TLS 配置，需要关闭
+			layered.CloseIdleConnections()
 		}
-		transport.TLSClientConfig.RootCAs = pool
 	} else {
-		// 当不添加证书时保持系统默认
-		transport.TLSClientConfig.RootCAs = nil
+		base = r.Client.Transport
+		if base == nil {
+			base = &http.Transport{}
+		}
 	}
 
-	// 配置代理
-	if r.Proxy != "" {
-		proxyURL, err := url.Parse(r.Proxy)
-		if err != nil {
-			return fmt.Errorf("解析代理URL失败: %w", err)
-		}
-		transport.Proxy = http.ProxyURL(proxyURL)
+	rt, err := r.buildTransport(base)
+	if err != nil {
+		return err
+	}
+	r.Client.Transport = &layeredTransport{owner: r, base: base, rt: rt}
+	r.transportStale = false
+	r.appliedConfig = r.transportConfig()
+	return nil
+}
+
+func (r *Req) configureTransport(transport *http.Transport, verify bool) error {
+	// 配置TLS
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	if err := r.configureTLS(transport.TLSClientConfig, verify); err != nil {
+		return err
+	}
+
+	// 配置拨号方式
+	dialer, installed, err := r.newDialer()
+	if err != nil {
+		return err
+	}
+	if installed {
+		transport.DialContext = dialer.DialContext
+	}
+
+	// 配置代理，代理链的第一跳使用同样的拨号方式
+	if err := r.configureProxy(transport, dialer); err != nil {
+		return err
 	}
 
+	// 配置协议版本与连接池
+	r.configureProtocol(transport)
 	return nil
 }
 
@@ -0,0 +1,226 @@
+package nettools
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/coutcin-xw/go-logs"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// legacyInsecureDefault 为 true 时 NewRequest 恢复旧版默认不校验证书的行为
+var legacyInsecureDefault atomic.Bool
+
+// SetLegacyInsecureDefault 迁移开关：开启后 NewRequest 创建的请求默认跳过证书校验，
+// 仅供尚未适配安全默认值的旧调用方显式使用
+func SetLegacyInsecureDefault(enable bool) {
+	if enable {
+		logs.Log.Warn("已启用旧版TLS默认行为，新建请求将默认跳过证书校验")
+	}
+	legacyInsecureDefault.Store(enable)
+}
+
+// AddInsecureHost 对指定主机跳过证书校验，匹配规则与 NO_PROXY 相同（域名后缀、IP、CIDR）
+func (r *Req) AddInsecureHost(hosts ...string) *Req {
+	logs.Log.Warnf("以下主机将跳过TLS证书校验: %s", strings.Join(hosts, ", "))
+	r.InsecureHosts = append(r.InsecureHosts, hosts...)
+	r.transportStale = true
+	return r
+}
+
+// SetClientCert 从 PEM 格式的证书和私钥文件加载客户端证书
+func (r *Req) SetClientCert(certFile, keyFile string) *Req {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		r.tlsErr = fmt.Errorf("加载客户端证书失败: %w", err)
+		return r
+	}
+	r.ClientCerts = append(r.ClientCerts, cert)
+	r.transportStale = true
+	return r
+}
+
+// SetClientCertPEM 从内存中的 PEM 数据加载客户端证书
+func (r *Req) SetClientCertPEM(certPEM, keyPEM []byte) *Req {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		r.tlsErr = fmt.Errorf("加载客户端证书失败: %w", err)
+		return r
+	}
+	r.ClientCerts = append(r.ClientCerts, cert)
+	r.transportStale = true
+	return r
+}
+
+// SetClientCertPKCS12 从 PKCS#12 (.p12/.pfx) 数据加载客户端证书
+func (r *Req) SetClientCertPKCS12(data []byte, password string) *Req {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		r.tlsErr = fmt.Errorf("解析PKCS#12证书失败: %w", err)
+		return r
+	}
+	tlsCert := tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+	for _, ca := range caCerts {
+		tlsCert.Certificate = append(tlsCert.Certificate, ca.Raw)
+	}
+	r.ClientCerts = append(r.ClientCerts, tlsCert)
+	r.transportStale = true
+	return r
+}
+
+// SetClientCertPKCS12File 从 PKCS#12 文件加载客户端证书
+func (r *Req) SetClientCertPKCS12File(path, password string) *Req {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r.tlsErr = fmt.Errorf("读取PKCS#12证书失败: %w", err)
+		return r
+	}
+	return r.SetClientCertPKCS12(data, password)
+}
+
+// AddCACertPEM 添加内存中的 PEM 格式 CA 证书
+func (r *Req) AddCACertPEM(pem []byte) *Req {
+	r.CACertPEMs = append(r.CACertPEMs, pem)
+	r.transportStale = true
+	return r
+}
+
+// SetTLSVersion 设置 TLS 最低/最高版本，传 0 表示使用默认值
+func (r *Req) SetTLSVersion(min, max uint16) *Req {
+	r.MinTLSVersion = min
+	r.MaxTLSVersion = max
+	r.transportStale = true
+	return r
+}
+
+func (r *Req) SetCipherSuites(suites ...uint16) *Req {
+	r.CipherSuites = suites
+	r.transportStale = true
+	return r
+}
+
+// SetServerName 覆盖 TLS 握手使用的 SNI，同时用于证书主机名校验
+func (r *Req) SetServerName(name string) *Req {
+	r.ServerName = name
+	r.transportStale = true
+	return r
+}
+
+// AddPinnedSPKI 添加证书公钥固定，值为 SubjectPublicKeyInfo 的 SHA-256 Base64，可带 "sha256/" 前缀
+func (r *Req) AddPinnedSPKI(hashes ...string) *Req {
+	for _, hash := range hashes {
+		r.PinnedSPKI = append(r.PinnedSPKI, strings.TrimPrefix(hash, "sha256/"))
+	}
+	r.transportStale = true
+	return r
+}
+
+// SPKIHash 计算证书公钥的 SHA-256 Base64 值，用于 AddPinnedSPKI
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// configureTLS 将 Req 上的 TLS 选项应用到 tls.Config
+func (r *Req) configureTLS(config *tls.Config, verify bool) error {
+	if r.tlsErr != nil {
+		return r.tlsErr
+	}
+
+	config.InsecureSkipVerify = !verify
+
+	if len(r.CertPaths) > 0 || len(r.CACertPEMs) > 0 {
+		// 使用系统证书池作为基础
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		// 添加自定义证书
+		for _, path := range r.CertPaths {
+			cert, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("读取证书失败: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(cert) {
+				return fmt.Errorf("添加证书到池失败: %s", path)
+			}
+		}
+		for i, cert := range r.CACertPEMs {
+			if !pool.AppendCertsFromPEM(cert) {
+				return fmt.Errorf("添加证书到池失败: 第%d个PEM", i+1)
+			}
+		}
+		config.RootCAs = pool
+	} else {
+		// 当不添加证书时保持系统默认
+		config.RootCAs = nil
+	}
+
+	config.Certificates = r.ClientCerts
+	config.MinVersion = r.MinTLSVersion
+	config.MaxVersion = r.MaxTLSVersion
+	config.CipherSuites = r.CipherSuites
+	config.ServerName = r.ServerName
+
+	if len(r.PinnedSPKI) > 0 {
+		pins := r.PinnedSPKI
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPinnedSPKI(cs, pins, !verify)
+		}
+	} else {
+		config.VerifyConnection = nil
+	}
+
+	return nil
+}
+
+// verifyPinnedSPKI 要求校验通过的证书链中至少有一张证书的公钥命中固定值；
+// 服务端发送的 PeerCertificates 未经校验，可附带任意证书，只有跳过校验时才退而比对叶子证书
+func verifyPinnedSPKI(cs tls.ConnectionState, pins []string, insecure bool) error {
+	chains := cs.VerifiedChains
+	if insecure && len(cs.PeerCertificates) > 0 {
+		chains = [][]*x509.Certificate{cs.PeerCertificates[:1]}
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			hash := SPKIHash(cert)
+			for _, pin := range pins {
+				if hash == pin {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("证书公钥固定校验失败: %s", cs.ServerName)
+}
+
+// hostTLSTransport 将命中 InsecureHosts 的请求交给跳过校验的 Transport，其余请求正常校验
+type hostTLSTransport struct {
+	secure   *http.Transport
+	insecure *http.Transport
+	hosts    []string
+}
+
+func (t *hostTLSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "https" && MatchNoProxy(req.URL.Host, t.hosts) {
+		return t.insecure.RoundTrip(req)
+	}
+	return t.secure.RoundTrip(req)
+}
+
+func (t *hostTLSTransport) CloseIdleConnections() {
+	t.secure.CloseIdleConnections()
+	t.insecure.CloseIdleConnections()
+}
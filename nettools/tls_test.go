@@ -0,0 +1,148 @@
+package nettools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func genClientCert(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nettools-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return cert, key, certPEM, keyPEM
+}
+
+func TestMutualTLS(t *testing.T) {
+	clientCert, clientKey, certPEM, keyPEM := genClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	srv, serverPEM := newTLSServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}), func(srv *httptest.Server) {
+		srv.TLS = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+			MaxVersion: tls.VersionTLS12,
+		}
+	})
+	newReq := func() *Req {
+		return NewRequest().SetUrl(srv.URL).Get().SetVerify(true).AddCACertPEM(serverPEM)
+	}
+
+	body, err := newReq().SetClientCertPEM(certPEM, keyPEM).DoAndGetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "nettools-client" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	p12, err := pkcs12.Modern.Encode(clientKey, clientCert, nil, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newReq().SetClientCertPKCS12(p12, "secret").DoAndGetBody(); err != nil {
+		t.Fatalf("pkcs12: %v", err)
+	}
+	if _, err := newReq().SetClientCertPKCS12(p12, "wrong").Do(); err == nil {
+		t.Fatal("expected pkcs12 password error")
+	}
+
+	if _, err := newReq().Do(); err == nil {
+		t.Fatal("expected handshake failure without client cert")
+	}
+
+	if _, err := newReq().SetClientCertPEM(certPEM, keyPEM).
+		SetServerName("example.com").
+		AddPinnedSPKI("sha256/" + SPKIHash(srv.Certificate())).
+		DoAndGetBody(); err != nil {
+		t.Fatalf("sni/pin: %v", err)
+	}
+	if _, err := newReq().SetClientCertPEM(certPEM, keyPEM).
+		AddPinnedSPKI(SPKIHash(clientCert)).Do(); err == nil {
+		t.Fatal("expected pin mismatch")
+	}
+
+	if _, err := newReq().SetClientCertPEM(certPEM, keyPEM).
+		SetTLSVersion(tls.VersionTLS13, 0).Do(); err == nil {
+		t.Fatal("expected version mismatch")
+	}
+}
+
+func TestPinnedSPKIVerifiedChain(t *testing.T) {
+	pinned, _, _, _ := genClientCert(t)
+	srv, serverPEM := newTLSServer(t, nil)
+	// 服务端在有效证书链后附带一张与链无关的证书，其公钥不能满足固定校验
+	leaf := &srv.TLS.Certificates[0]
+	leaf.Certificate = append(leaf.Certificate, pinned.Raw)
+
+	newReq := func() *Req {
+		return NewRequest().SetUrl(srv.URL).Get().SetVerify(true).AddCACertPEM(serverPEM)
+	}
+	if _, err := newReq().AddPinnedSPKI(SPKIHash(pinned)).Do(); err == nil {
+		t.Fatal("expected pin on unverified extra certificate to fail")
+	}
+	if _, err := newReq().AddPinnedSPKI(SPKIHash(srv.Certificate())).DoAndGetBody(); err != nil {
+		t.Fatalf("pin on verified leaf: %v", err)
+	}
+	// 跳过校验时只比对叶子证书
+	if _, err := NewRequest().SetUrl(srv.URL).Get().SetVerify(false).AddPinnedSPKI(SPKIHash(pinned)).Do(); err == nil {
+		t.Fatal("expected insecure pin to ignore extra certificate")
+	}
+	if _, err := NewRequest().SetUrl(srv.URL).Get().SetVerify(false).AddPinnedSPKI(SPKIHash(srv.Certificate())).DoAndGetBody(); err != nil {
+		t.Fatalf("insecure pin on leaf: %v", err)
+	}
+}
+
+func TestSecureDefaults(t *testing.T) {
+	srv, _ := newTLSServer(t, nil)
+
+	if _, err := NewRequest().SetUrl(srv.URL).Get().Do(); err == nil {
+		t.Fatal("expected verification failure by default")
+	}
+	if _, err := NewRequest().SetUrl(srv.URL).Get().AddInsecureHost("127.0.0.0/8").DoAndGetBody(); err != nil {
+		t.Fatalf("insecure host: %v", err)
+	}
+	if _, err := NewRequest().SetUrl(srv.URL).Get().AddInsecureHost("example.com").Do(); err == nil {
+		t.Fatal("expected verification failure for non-matching host")
+	}
+	if _, err := NewRequest().SetUrl(srv.URL).Get().SetVerify(false).DoAndGetBody(); err != nil {
+		t.Fatalf("opt-out: %v", err)
+	}
+
+	SetLegacyInsecureDefault(true)
+	defer SetLegacyInsecureDefault(false)
+	if _, err := NewRequest().SetUrl(srv.URL).Get().DoAndGetBody(); err != nil {
+		t.Fatalf("legacy default: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package nettools
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// TraceInfo 表示一次请求的耗时分解，发生重定向时连接相关字段为最后一跳的数据
+type TraceInfo struct {
+	DNSLookup        time.Duration // DNS 解析
+	TCPConnect       time.Duration // TCP 建连
+	TLSHandshake     time.Duration // TLS 握手
+	ServerProcessing time.Duration // 请求写完到收到首字节
+	TimeToFirstByte  time.Duration // 从发起请求到收到首字节
+	ContentTransfer  time.Duration // 首字节到响应体读取完毕
+	Total            time.Duration // 从发起请求到响应体读取完毕（未读完时截止到首字节）
+	ConnReused       bool          // 是否复用了连接
+	ConnWasIdle      bool          // 复用的连接是否来自空闲池
+	ConnIdleTime     time.Duration // 复用前的空闲时长
+	RemoteAddr       string
+	BodyDone         bool // 响应体是否已读取完毕或关闭
+}
+
+func (t TraceInfo) String() string {
+	return fmt.Sprintf("dns=%s connect=%s tls=%s server=%s ttfb=%s transfer=%s total=%s reused=%t remote=%s",
+		t.DNSLookup, t.TCPConnect, t.TLSHandshake, t.ServerProcessing,
+		t.TimeToFirstByte, t.ContentTransfer, t.Total, t.ConnReused, t.RemoteAddr)
+}
+
+func (r *Req) SetTrace(enable bool) *Req {
+	r.Trace = enable
+	return r
+}
+
+// GetTraceInfo 返回开启 SetTrace 的请求的耗时分解
+func GetTraceInfo(resp *http.Response) (TraceInfo, bool) {
+	if resp == nil || resp.Request == nil {
+		return TraceInfo{}, false
+	}
+	rec, ok := resp.Request.Context().Value(traceKey{}).(*traceRecorder)
+	if !ok {
+		return TraceInfo{}, false
+	}
+	return rec.info(), true
+}
+
+type traceKey struct{}
+
+// traceRecorder 记录 httptrace 回调的时间点，回调可能来自拨号协程，需加锁
+type traceRecorder struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	bodyDone     time.Time
+
+	reused     bool
+	wasIdle    bool
+	idleTime   time.Duration
+	remoteAddr string
+}
+
+func newTraceRecorder() *traceRecorder {
+	return &traceRecorder{start: time.Now()}
+}
+
+func (t *traceRecorder) withContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, traceKey{}, t)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(&t.dnsDone)
+		},
+		ConnectStart: func(_, _ string) {
+			t.mu.Lock()
+			// 多地址并发拨号时只记录第一次开始
+			if t.connectStart.IsZero() || !t.connectDone.IsZero() {
+				t.connectStart = time.Now()
+				t.connectDone = time.Time{}
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.set(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.set(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.wasIdle = info.WasIdle
+			t.idleTime = info.IdleTime
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.set(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.set(&t.firstByte)
+		},
+	})
+}
+
+func (t *traceRecorder) set(field *time.Time) {
+	t.mu.Lock()
+	*field = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *traceRecorder) info() TraceInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info := TraceInfo{
+		DNSLookup:        span(t.dnsStart, t.dnsDone),
+		TCPConnect:       span(t.connectStart, t.connectDone),
+		TLSHandshake:     span(t.tlsStart, t.tlsDone),
+		ServerProcessing: span(t.wroteRequest, t.firstByte),
+		TimeToFirstByte:  span(t.start, t.firstByte),
+		ContentTransfer:  span(t.firstByte, t.bodyDone),
+		ConnReused:       t.reused,
+		ConnWasIdle:      t.wasIdle,
+		ConnIdleTime:     t.idleTime,
+		RemoteAddr:       t.remoteAddr,
+		BodyDone:         !t.bodyDone.IsZero(),
+	}
+	if info.BodyDone {
+		info.Total = span(t.start, t.bodyDone)
+	} else {
+		info.Total = info.TimeToFirstByte
+	}
+	return info
+}
+
+func span(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// wrapBody 在响应体读到 EOF 或被关闭时记录传输结束时间
+func (t *traceRecorder) wrapBody(body io.ReadCloser) io.ReadCloser {
+	return &traceBody{ReadCloser: body, rec: t}
+}
+
+type traceBody struct {
+	io.ReadCloser
+	rec  *traceRecorder
+	once sync.Once
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *traceBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+func (b *traceBody) done() {
+	b.once.Do(func() { b.rec.set(&b.rec.bodyDone) })
+}
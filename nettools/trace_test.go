@@ -0,0 +1,44 @@
+package nettools
+
+import (
+	"testing"
+)
+
+func TestTraceInfo(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, nil)
+	req := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM).SetTrace(true)
+
+	for i, wantReused := range []bool{false, true} {
+		resp, err := req.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadResponseBody(resp); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		info, ok := GetTraceInfo(resp)
+		if !ok {
+			t.Fatal("trace info missing")
+		}
+		if info.ConnReused != wantReused {
+			t.Fatalf("request %d: reused=%v, want %v", i, info.ConnReused, wantReused)
+		}
+		if !wantReused && (info.TCPConnect <= 0 || info.TLSHandshake <= 0) {
+			t.Fatalf("missing connect timings: %s", info)
+		}
+		if info.TimeToFirstByte <= 0 || !info.BodyDone || info.Total < info.TimeToFirstByte {
+			t.Fatalf("unexpected timings: %s", info)
+		}
+	}
+
+	resp, err := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, ok := GetTraceInfo(resp); ok {
+		t.Fatal("trace info should be absent when tracing is disabled")
+	}
+}
@@ -0,0 +1,246 @@
+package nettools
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// TransportWrapper 表示包裹其他 RoundTripper 的中间层（如追踪、故障注入）。
+// 实现该接口后，Req 会沿 Unwrap 找到最内层的 *http.Transport 应用 TLS 与代理配置，并保留各层包装
+type TransportWrapper interface {
+	http.RoundTripper
+	Unwrap() http.RoundTripper
+}
+
+// WrapTransport 添加 Transport 包装层，先添加的位于最外层；
+// TLS、代理等配置始终应用于最内层的 *http.Transport
+func (r *Req) WrapTransport(wrappers ...func(next http.RoundTripper) http.RoundTripper) *Req {
+	r.TransportWrappers = append(r.TransportWrappers, wrappers...)
+	r.transportStale = true
+	return r
+}
+
+// RefreshTransport 使下次请求重新配置 Transport。直接修改 TLS、代理等字段时，
+// 下次请求会与上次组装时的配置比较并自动重新配置；只有替换了同一函数字面量创建的
+// DialContext 或 TransportWrappers 闭包等无法区分的情况下才需要调用
+func (r *Req) RefreshTransport() *Req {
+	r.transportStale = true
+	return r
+}
+
+// transportConfig 表示组装 Transport 时使用的配置快照，函数字段按函数地址比较
+type transportConfig struct {
+	verify        bool
+	certPaths     []string
+	proxy         string
+	proxyChain    []string
+	proxyHeaders  map[string]string
+	proxyPool     *ProxyPool
+	proxyFromEnv  bool
+	noProxy       []string
+	clientCerts   [][][]byte
+	caCertPEMs    [][]byte
+	minTLSVersion uint16
+	maxTLSVersion uint16
+	cipherSuites  []uint16
+	serverName    string
+	pinnedSPKI    []string
+	insecureHosts []string
+	wrappers      []uintptr
+	httpVersion   HTTPVersion
+	connPool      *ConnPoolConfig
+	unixSocket    string
+	dialContext   uintptr
+	localAddr     string
+	iface         string
+	ipFamily      IPFamily
+	resolver      *Resolver
+	fallbackDelay time.Duration
+}
+
+// transportConfig 复制当前配置，之后对 Req 字段的修改不影响快照
+func (r *Req) transportConfig() *transportConfig {
+	c := &transportConfig{
+		verify:        r.Verify,
+		certPaths:     slices.Clone(r.CertPaths),
+		proxy:         r.Proxy,
+		proxyChain:    slices.Clone(r.ProxyChain),
+		proxyHeaders:  maps.Clone(r.ProxyHeaders),
+		proxyPool:     r.ProxyPool,
+		proxyFromEnv:  r.ProxyFromEnv,
+		noProxy:       slices.Clone(r.NoProxy),
+		minTLSVersion: r.MinTLSVersion,
+		maxTLSVersion: r.MaxTLSVersion,
+		cipherSuites:  slices.Clone(r.CipherSuites),
+		serverName:    r.ServerName,
+		pinnedSPKI:    slices.Clone(r.PinnedSPKI),
+		insecureHosts: slices.Clone(r.InsecureHosts),
+		httpVersion:   r.HTTPVersion,
+		unixSocket:    r.UnixSocket,
+		dialContext:   funcAddr(r.DialContext),
+		localAddr:     r.LocalAddr,
+		iface:         r.Interface,
+		ipFamily:      r.IPFamily,
+		resolver:      r.Resolver,
+		fallbackDelay: r.FallbackDelay,
+	}
+	for _, cert := range r.ClientCerts {
+		c.clientCerts = append(c.clientCerts, slices.Clone(cert.Certificate))
+	}
+	for _, pem := range r.CACertPEMs {
+		c.caCertPEMs = append(c.caCertPEMs, bytes.Clone(pem))
+	}
+	for _, wrapper := range r.TransportWrappers {
+		c.wrappers = append(c.wrappers, funcAddr(wrapper))
+	}
+	if r.ConnPool != nil {
+		pool := *r.ConnPool
+		c.connPool = &pool
+	}
+	return c
+}
+
+// matches 判断快照与 Req 当前的配置是否一致
+func (c *transportConfig) matches(r *Req) bool {
+	if c.verify != r.Verify || c.proxy != r.Proxy || c.proxyPool != r.ProxyPool || c.proxyFromEnv != r.ProxyFromEnv ||
+		c.minTLSVersion != r.MinTLSVersion || c.maxTLSVersion != r.MaxTLSVersion || c.serverName != r.ServerName ||
+		c.httpVersion != r.HTTPVersion || c.unixSocket != r.UnixSocket || c.dialContext != funcAddr(r.DialContext) ||
+		c.localAddr != r.LocalAddr || c.iface != r.Interface || c.ipFamily != r.IPFamily ||
+		c.resolver != r.Resolver || c.fallbackDelay != r.FallbackDelay {
+		return false
+	}
+	if !slices.Equal(c.certPaths, r.CertPaths) || !slices.Equal(c.proxyChain, r.ProxyChain) ||
+		!maps.Equal(c.proxyHeaders, r.ProxyHeaders) || !slices.Equal(c.noProxy, r.NoProxy) ||
+		!slices.Equal(c.cipherSuites, r.CipherSuites) || !slices.Equal(c.pinnedSPKI, r.PinnedSPKI) ||
+		!slices.Equal(c.insecureHosts, r.InsecureHosts) || !slices.EqualFunc(c.caCertPEMs, r.CACertPEMs, bytes.Equal) {
+		return false
+	}
+	if !slices.EqualFunc(c.clientCerts, r.ClientCerts, func(chain [][]byte, cert tls.Certificate) bool {
+		return slices.EqualFunc(chain, cert.Certificate, bytes.Equal)
+	}) {
+		return false
+	}
+	if !slices.EqualFunc(c.wrappers, r.TransportWrappers, func(addr uintptr, wrapper func(http.RoundTripper) http.RoundTripper) bool {
+		return addr == funcAddr(wrapper)
+	}) {
+		return false
+	}
+	if (c.connPool == nil) != (r.ConnPool == nil) || c.connPool != nil && *c.connPool != *r.ConnPool {
+		return false
+	}
+	return true
+}
+
+func funcAddr(fn any) uintptr {
+	v := reflect.ValueOf(fn)
+	if !v.IsValid() || v.IsNil() {
+		return 0
+	}
+	return v.Pointer()
+}
+
+// innermostTransport 沿 Unwrap 链找到最内层的 *http.Transport，找不到时返回 nil
+func innermostTransport(rt http.RoundTripper) *http.Transport {
+	for rt != nil {
+		switch t := rt.(type) {
+		case *http.Transport:
+			return t
+		case *hostTLSTransport:
+			return t.secure
+		case TransportWrapper:
+			rt = t.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// layeredTransport 表示 Req 组装好的 Transport：base 为调用方提供的 Transport，
+// rt 为加上 InsecureHosts 分流与 TransportWrappers 后的最终结果
+type layeredTransport struct {
+	owner  *Req
+	base   http.RoundTripper
+	rt     http.RoundTripper
+	shared atomic.Bool // base 已被 Clone 共享，重新组装前需要复制
+}
+
+func (t *layeredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.rt.RoundTrip(req)
+}
+
+func (t *layeredTransport) Unwrap() http.RoundTripper {
+	return t.rt
+}
+
+func (t *layeredTransport) CloseIdleConnections() {
+	for rt := t.rt; rt != nil; {
+		if closer, ok := rt.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+			return
+		}
+		wrapper, ok := rt.(TransportWrapper)
+		if !ok {
+			return
+		}
+		rt = wrapper.Unwrap()
+	}
+}
+
+// cloneTransport 复制共享的基础 Transport，不含 *http.Transport 的 RoundTripper 无需配置，原样返回
+func cloneTransport(base http.RoundTripper) (http.RoundTripper, error) {
+	if t, ok := base.(*http.Transport); ok {
+		return t.Clone(), nil
+	}
+	if innermostTransport(base) == nil {
+		return base, nil
+	}
+	return nil, fmt.Errorf("克隆的请求无法复制自定义的 Transport 包装层，请在 Clone 之前完成 TLS 与代理配置，或使用 WrapTransport")
+}
+
+// buildTransport 在 base 的最内层 *http.Transport 上应用配置并组装各层包装
+func (r *Req) buildTransport(base http.RoundTripper) (http.RoundTripper, error) {
+	rt := base
+	inner := innermostTransport(base)
+	if inner == http.DefaultTransport {
+		return nil, fmt.Errorf("不能修改 http.DefaultTransport，请为包装层提供独立的 *http.Transport")
+	}
+
+	if inner != nil {
+		if err := r.configureTransport(inner, r.Verify); err != nil {
+			return nil, err
+		}
+		// 配置按主机跳过证书校验，分流层需要直接替换最内层 Transport
+		if r.Verify && len(r.InsecureHosts) > 0 {
+			if base != http.RoundTripper(inner) {
+				return nil, fmt.Errorf("InsecureHosts 不支持自定义的 Transport 包装层，请使用 WrapTransport")
+			}
+			if r.insecureTransport == nil {
+				r.insecureTransport = inner.Clone()
+			}
+			if err := r.configureTransport(r.insecureTransport, false); err != nil {
+				return nil, err
+			}
+			rt = &hostTLSTransport{secure: inner, insecure: r.insecureTransport, hosts: r.InsecureHosts}
+		}
+		switch r.HTTPVersion {
+		case HTTP2:
+			rt = newHTTP2Transport(rt, inner)
+		case HTTP3, HTTP3Only:
+			rt = r.newHTTP3Transport(rt, inner)
+		}
+	}
+	// 其他自定义 RoundTripper（如 mocknet.Transport）原样使用，TLS 与代理配置不生效
+
+	for i := len(r.TransportWrappers) - 1; i >= 0; i-- {
+		rt = r.TransportWrappers[i](rt)
+	}
+	return rt, nil
+}
@@ -0,0 +1,212 @@
+package nettools
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingTransport 统计经过的请求数，实现 TransportWrapper
+type countingTransport struct {
+	next  http.RoundTripper
+	count atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.count.Add(1)
+	return c.next.RoundTrip(req)
+}
+
+func (c *countingTransport) Unwrap() http.RoundTripper { return c.next }
+
+// newTLSServer 启动 TLS 测试服务器并返回其证书 PEM，handler 为空时响应 "ok"；
+// configure 在启动前调用，用于开启 HTTP/2 或调整 TLS 配置
+func newTLSServer(t *testing.T, handler http.Handler, configure ...func(*httptest.Server)) (*httptest.Server, []byte) {
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	for _, fn := range configure {
+		fn(srv)
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, serverCertPEM(srv)
+}
+
+// withHTTP2 让 newTLSServer 启动的服务器支持 HTTP/2
+func withHTTP2(srv *httptest.Server) { srv.EnableHTTP2 = true }
+
+func serverCertPEM(srv *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+}
+
+func TestWrapTransport(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, nil)
+
+	var outer, inner *countingTransport
+	req := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM).WrapTransport(
+		func(next http.RoundTripper) http.RoundTripper {
+			outer = &countingTransport{next: next}
+			return outer
+		},
+		func(next http.RoundTripper) http.RoundTripper {
+			inner = &countingTransport{next: next}
+			return inner
+		},
+	)
+	for i := 0; i < 2; i++ {
+		if _, err := req.DoAndGetBody(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if outer.count.Load() != 2 || inner.count.Load() != 2 {
+		t.Fatalf("wrapper counts: outer=%d inner=%d", outer.count.Load(), inner.count.Load())
+	}
+	if outer.next != inner {
+		t.Fatal("first wrapper should be outermost")
+	}
+	if _, ok := inner.next.(*http.Transport); !ok {
+		t.Fatalf("innermost should be *http.Transport, got %T", inner.next)
+	}
+}
+
+func TestCustomTransportWrapper(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, nil)
+
+	base := &http.Transport{}
+	wrapper := &countingTransport{next: base}
+	req := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM)
+	req.Client.Transport = wrapper
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	if wrapper.count.Load() != 1 {
+		t.Fatalf("wrapper bypassed: %d", wrapper.count.Load())
+	}
+	if base.TLSClientConfig == nil || base.TLSClientConfig.RootCAs == nil {
+		t.Fatal("TLS config not applied to innermost transport")
+	}
+}
+
+func TestTransportConfiguredOnce(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, nil)
+
+	req := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM)
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	layered := req.Client.Transport.(*layeredTransport)
+	config := layered.base.(*http.Transport).TLSClientConfig
+
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	if req.Client.Transport != layered {
+		t.Fatal("transport rebuilt without configuration change")
+	}
+	if layered.base.(*http.Transport).TLSClientConfig != config {
+		t.Fatal("TLS config mutated without configuration change")
+	}
+
+	// 修改配置后重新组装，基础 Transport 保持不变
+	req.SetServerName("nettools.invalid")
+	if _, err := req.Do(); err == nil {
+		t.Fatal("expected name mismatch after SetServerName")
+	}
+	rebuilt := req.Client.Transport.(*layeredTransport)
+	if rebuilt == layered || rebuilt.base != layered.base {
+		t.Fatal("expected rebuild over the same base transport")
+	}
+	if rebuilt.base.(*http.Transport).TLSClientConfig.ServerName != "nettools.invalid" {
+		t.Fatal("server name not applied")
+	}
+}
+
+func TestTransportFieldChanges(t *testing.T) {
+	srv, serverPEM := newTLSServer(t, nil)
+
+	// 直接修改字段同样会在下次请求时重新组装
+	req := NewRequest().SetUrl(srv.URL).Get().AddCACertPEM(serverPEM)
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	req.ServerName = "nettools.invalid"
+	if _, err := req.Do(); err == nil {
+		t.Fatal("expected name mismatch after assigning ServerName")
+	}
+	req.ServerName = ""
+	req.PinnedSPKI = []string{SPKIHash(srv.Certificate())}
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	// 原地修改切片元素
+	req.PinnedSPKI[0] = "bogus"
+	if _, err := req.Do(); err == nil {
+		t.Fatal("expected pin mismatch after modifying PinnedSPKI in place")
+	}
+	req.PinnedSPKI = nil
+	req.ConnPool = &ConnPoolConfig{MaxConnsPerHost: 3}
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	req.ConnPool.MaxConnsPerHost = 5
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	if got := innermostTransport(req.Client.Transport).MaxConnsPerHost; got != 5 {
+		t.Fatalf("MaxConnsPerHost = %d, want 5", got)
+	}
+}
+
+func TestCloneConfigureError(t *testing.T) {
+	tmpl := NewRequest().SetUrl("http://127.0.0.1:1/").Get().SetCertPaths([]string{"/nonexistent/ca.pem"})
+	clone := tmpl.Clone()
+	if clone.cloneErr == nil {
+		t.Fatal("Clone did not record the configuration error")
+	}
+	if _, err := clone.Do(); err == nil || !strings.Contains(err.Error(), "读取证书失败") {
+		t.Fatalf("expected template configuration error, got %v", err)
+	}
+}
+
+func TestWrapTransportInsecureHosts(t *testing.T) {
+	srv, _ := newTLSServer(t, nil)
+
+	var wrapper *countingTransport
+	req := NewRequest().SetUrl(srv.URL).Get().AddInsecureHost("127.0.0.0/8").
+		WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			wrapper = &countingTransport{next: next}
+			return wrapper
+		})
+	if _, err := req.DoAndGetBody(); err != nil {
+		t.Fatal(err)
+	}
+	if wrapper.count.Load() != 1 {
+		t.Fatal("wrapper bypassed")
+	}
+
+	// 调用方自带包装层时无法替换最内层 Transport
+	req = NewRequest().SetUrl(srv.URL).Get().AddInsecureHost("127.0.0.0/8")
+	req.Client.Transport = &countingTransport{next: &http.Transport{}}
+	if _, err := req.Do(); err == nil || !strings.Contains(err.Error(), "WrapTransport") {
+		t.Fatalf("expected InsecureHosts error, got %v", err)
+	}
+}
+
+func TestDefaultTransportUntouched(t *testing.T) {
+	config := http.DefaultTransport.(*http.Transport).TLSClientConfig
+	req := NewRequest().SetUrl("http://127.0.0.1/").Get()
+	req.Client.Transport = &countingTransport{next: http.DefaultTransport}
+	if _, err := req.Do(); err == nil {
+		t.Fatal("expected error when innermost transport is http.DefaultTransport")
+	}
+	if http.DefaultTransport.(*http.Transport).TLSClientConfig != config {
+		t.Fatal("http.DefaultTransport mutated")
+	}
+}
@@ -0,0 +1,335 @@
+package nettools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coder/websocket"
+)
+
+// WebSocket 消息类型，取值与 RFC 6455 的操作码一致
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// WebSocket 关闭码（RFC 6455 7.4.1）
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const (
+	// defaultWebSocketReadLimit 为未设置 ReadLimit 与 MaxBodySize 时单条消息的大小上限
+	defaultWebSocketReadLimit = 32 << 20
+	websocketMaxCloseReason   = 123 // 关闭帧数据最多 125 字节，其中 2 字节为关闭码
+)
+
+var (
+	// ErrWebSocketHandshake 表示 WebSocket 握手失败
+	ErrWebSocketHandshake = errors.New("WebSocket握手失败")
+	// ErrWebSocketClosed 表示 WebSocket 连接已关闭
+	ErrWebSocketClosed = errors.New("WebSocket连接已关闭")
+)
+
+// WebSocketCloseError 表示对端发送的关闭帧，可用 errors.Is(err, ErrWebSocketClosed) 判断
+type WebSocketCloseError struct {
+	Code int
+	Text string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("%v: %d %s", ErrWebSocketClosed, e.Code, e.Text)
+}
+
+func (e *WebSocketCloseError) Unwrap() error { return ErrWebSocketClosed }
+
+// WebSocketConfig 表示 WebSocket 连接参数
+type WebSocketConfig struct {
+	Subprotocols      []string      // 按优先级排列的子协议
+	EnableCompression bool          // 协商 permessage-deflate（RFC 7692），不使用上下文接管
+	PingInterval      time.Duration // 发送 ping 的间隔，0 表示不发送；下一次发送时仍未收到 pong 则关闭连接
+	ReadLimit         int64         // 单条消息（解压后）的大小上限，0 时沿用 MaxBodySize，两者都未设置时为 32MB；<0 表示不限制
+}
+
+// SetWebSocket 设置 DialWebSocket 使用的连接参数
+func (r *Req) SetWebSocket(config WebSocketConfig) *Req {
+	r.WebSocket = &config
+	return r
+}
+
+// DialWebSocket 使用 Req 的 URL、查询参数、请求头、Cookie，以及代理、TLS 与拨号配置建立 WebSocket 连接，
+// URL 可以是 ws/wss 或 http/https。握手通过 Req 的 http.Client（组装好的 Transport、Cookie 容器与重定向策略）发送，
+// 不经过中间件、限流、熔断与缓存，超时取 Ctx 与 Client.Timeout 中较早者。
+// 握手失败时返回的 *http.Response 可能非空，其中只保留响应体的前 1KB
+func (r *Req) DialWebSocket() (*WebSocketConn, *http.Response, error) {
+	if r.Url == "" {
+		return nil, nil, fmt.Errorf("请求URL不能为空")
+	}
+	reqUrl, err := r.buildURL()
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := url.Parse(reqUrl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析URL失败: %w", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss", "http", "https":
+	default:
+		return nil, nil, fmt.Errorf("不支持的WebSocket协议: %s", u.Scheme)
+	}
+	var config WebSocketConfig
+	if r.WebSocket != nil {
+		config = *r.WebSocket
+	}
+
+	r.mu.Lock()
+	err = r.configureClient()
+	client := *r.Client
+	r.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	header := &http.Request{Header: make(http.Header)}
+	r.setHeaders(header, "")
+	opts := &websocket.DialOptions{
+		HTTPClient:   &client,
+		HTTPHeader:   header.Header,
+		Subprotocols: config.Subprotocols,
+	}
+	if config.EnableCompression {
+		opts.CompressionMode = websocket.CompressionNoContextTakeover
+	}
+
+	ws, resp, err := websocket.Dial(r.context(), u.String(), opts)
+	if err != nil {
+		return nil, resp, fmt.Errorf("%w: %w", ErrWebSocketHandshake, err)
+	}
+
+	limit := config.ReadLimit
+	if limit == 0 {
+		limit = r.MaxBodySize
+	}
+	if limit == 0 {
+		limit = defaultWebSocketReadLimit
+	}
+	// 由 ReadMessage 按 limit 分块读取并判断是否超限
+	ws.SetReadLimit(-1)
+	c := &WebSocketConn{
+		ws:        ws,
+		readLimit: limit,
+		done:      make(chan struct{}),
+	}
+	if config.PingInterval > 0 {
+		go c.keepalive(config.PingInterval)
+	}
+	return c, resp, nil
+}
+
+func headerHasToken(header http.Header, key, token string) bool {
+	for _, value := range header.Values(key) {
+		for _, item := range strings.Split(value, ",") {
+			item, _, _ = strings.Cut(item, ";")
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isWebSocketUpgrade 判断请求是否为 WebSocket 握手，握手只能使用 HTTP/1.1
+func isWebSocketUpgrade(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket") && headerHasToken(req.Header, "Connection", "upgrade")
+}
+
+// WebSocketConn 表示客户端 WebSocket 连接。写入方法可以在多个协程中并发调用，
+// ReadMessage 同一时间只能由一个协程调用；对端的 ping 与关闭帧、以及 Ping 等待的 pong 都在 ReadMessage 中处理，
+// 因此需要有协程持续读取。读取出错（包括超时）后连接不可再读
+type WebSocketConn struct {
+	ws        *websocket.Conn
+	readLimit int64
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	readMu  sync.Mutex
+	readErr error
+
+	pongTimeout atomic.Bool
+	closeOnce   sync.Once
+	done        chan struct{} // 调用 Close 后关闭，用于结束心跳
+}
+
+// Subprotocol 返回服务器选择的子协议
+func (c *WebSocketConn) Subprotocol() string {
+	return c.ws.Subprotocol()
+}
+
+// SetReadDeadline 设置之后读取的超时时间，零值表示不超时；超时后连接关闭
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline 设置之后写入的超时时间，零值表示不超时；超时后连接关闭
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+// context 返回带有超时时间的 context
+func (c *WebSocketConn) context(deadline *time.Time) (context.Context, context.CancelFunc) {
+	c.mu.Lock()
+	t := *deadline
+	c.mu.Unlock()
+	if t.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), t)
+}
+
+// WriteMessage 发送文本或二进制消息，协商了压缩时消息会被压缩
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("不支持的消息类型: %d", messageType)
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return fmt.Errorf("文本消息不是有效的 UTF-8")
+	}
+	ctx, cancel := c.context(&c.writeDeadline)
+	defer cancel()
+	return c.wrapError(c.ws.Write(ctx, websocket.MessageType(messageType), data))
+}
+
+// Ping 发送 ping 并等待 pong，pong 由正在进行的 ReadMessage 接收
+func (c *WebSocketConn) Ping(ctx context.Context) error {
+	return c.wrapError(c.ws.Ping(ctx))
+}
+
+// Close 以 CloseNormal 关闭连接
+func (c *WebSocketConn) Close() error {
+	return c.CloseWithCode(CloseNormal, "")
+}
+
+// CloseWithCode 发送关闭帧，等待对端回应关闭帧（最多 5s）后关闭底层连接；
+// 没有其他协程在读取时，等待期间收到的消息会被丢弃。reason 超过 123 字节时被截断
+func (c *WebSocketConn) CloseWithCode(code int, reason string) error {
+	c.closeOnce.Do(func() { close(c.done) })
+	if len(reason) > websocketMaxCloseReason {
+		reason = reason[:websocketMaxCloseReason]
+	}
+	err := c.ws.Close(websocket.StatusCode(code), reason)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// ReadMessage 读取一条完整的消息，返回 TextMessage 或 BinaryMessage；
+// 对端关闭连接时返回 *WebSocketCloseError，消息超过 ReadLimit 时返回 BodyTooLargeError
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	ctx, cancel := c.context(&c.readDeadline)
+	defer cancel()
+	messageType, data, err = c.readMessage(ctx)
+	if err != nil {
+		c.readErr = c.wrapError(err)
+		return 0, nil, c.readErr
+	}
+	return messageType, data, nil
+}
+
+func (c *WebSocketConn) readMessage(ctx context.Context) (int, []byte, error) {
+	typ, reader, err := c.ws.Reader(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	// 按实际到达的数据逐块读取，不按帧头声明的长度预先分配
+	if c.readLimit > 0 {
+		reader = &limitedReader{r: reader, limit: c.readLimit}
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		if errors.Is(err, ErrBodyTooLarge) {
+			// 关闭握手在后台完成，不阻塞调用方
+			go c.ws.Close(websocket.StatusMessageTooBig, "")
+		}
+		return 0, nil, err
+	}
+	if typ == websocket.MessageText && !utf8.Valid(data) {
+		go c.ws.Close(websocket.StatusInvalidFramePayloadData, "")
+		return 0, nil, fmt.Errorf("文本消息不是有效的 UTF-8")
+	}
+	return int(typ), data, nil
+}
+
+// wrapError 把连接关闭相关的错误转换为 ErrWebSocketClosed 与 *WebSocketCloseError
+func (c *WebSocketConn) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var closeErr websocket.CloseError
+	switch {
+	case c.pongTimeout.Load():
+		return fmt.Errorf("%w: 心跳超时，未收到 pong", ErrWebSocketClosed)
+	case errors.As(err, &closeErr):
+		return &WebSocketCloseError{Code: int(closeErr.Code), Text: closeErr.Reason}
+	case errors.Is(err, net.ErrClosed):
+		return fmt.Errorf("%w: %w", ErrWebSocketClosed, err)
+	}
+	return err
+}
+
+// keepalive 定期发送 ping，一个间隔内没有收到 pong 时关闭连接
+func (c *WebSocketConn) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := c.ws.Ping(ctx)
+			cancel()
+			if errors.Is(err, context.DeadlineExceeded) {
+				c.pongTimeout.Store(true)
+				c.ws.CloseNow()
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package nettools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// newEchoServer 启动回显消息的 WebSocket 服务器，握手时记录请求头与 Cookie
+func newEchoServer(t *testing.T, tls bool, opts *websocket.AcceptOptions) (*httptest.Server, *atomic.Value) {
+	var handshake atomic.Value
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handshake.Store(r.Clone(context.Background()))
+		c, err := websocket.Accept(w, r, opts)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		c.SetReadLimit(1 << 20)
+		for {
+			typ, data, err := c.Read(r.Context())
+			if err != nil {
+				return
+			}
+			if err := c.Write(r.Context(), typ, data); err != nil {
+				return
+			}
+		}
+	})
+	if tls {
+		srv, _ := newTLSServer(t, handler, withHTTP2)
+		return srv, &handshake
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, &handshake
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	srv, handshake := newEchoServer(t, false, &websocket.AcceptOptions{Subprotocols: []string{"echo"}})
+
+	req := NewRequest().SetUrl(wsURL(srv)+"/chat").SetParams(map[string]interface{}{"room": "1"}).
+		SetHeader("X-Token", "secret").AddCookie(&http.Cookie{Name: "session", Value: "abc"}).
+		SetWebSocket(WebSocketConfig{Subprotocols: []string{"chat", "echo"}})
+	conn, resp, err := req.DialWebSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := handshake.Load().(*http.Request)
+	if r.URL.Query().Get("room") != "1" || r.Header.Get("X-Token") != "secret" {
+		t.Fatalf("unexpected handshake %s %v", r.URL, r.Header)
+	}
+	if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+		t.Fatalf("missing cookie: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || conn.Subprotocol() != "echo" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, conn.Subprotocol())
+	}
+
+	messages := []struct {
+		typ  int
+		data []byte
+	}{
+		{TextMessage, []byte("hello")},
+		{BinaryMessage, []byte{0, 1, 2, 0xff}},
+		{TextMessage, bytes.Repeat([]byte("x"), 70000)}, // 64 位长度
+		{TextMessage, []byte{}},
+	}
+	for _, m := range messages {
+		if err := conn.WriteMessage(m.typ, m.data); err != nil {
+			t.Fatal(err)
+		}
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != m.typ || !bytes.Equal(data, m.data) {
+			t.Fatalf("echo mismatch: type %d, %d bytes", typ, len(data))
+		}
+	}
+
+	if err := conn.WriteMessage(TextMessage, []byte{0xff}); err == nil {
+		t.Fatal("expected invalid UTF-8 error")
+	}
+
+	// Ping 等待的 pong 由正在进行的 ReadMessage 接收
+	read := make(chan error, 1)
+	go func() {
+		_, _, err := conn.ReadMessage()
+		read <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := conn.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	conn.WriteMessage(TextMessage, []byte("done"))
+	if err := <-read; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	srv, handshake := newEchoServer(t, false, &websocket.AcceptOptions{CompressionMode: websocket.CompressionNoContextTakeover})
+
+	conn, resp, err := NewRequest().SetUrl(wsURL(srv)).SetWebSocket(WebSocketConfig{EnableCompression: true}).DialWebSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if !headerHasToken(resp.Header, "Sec-WebSocket-Extensions", "permessage-deflate") {
+		t.Fatal("permessage-deflate not negotiated")
+	}
+	if ext := handshake.Load().(*http.Request).Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("unexpected extensions %q", ext)
+	}
+
+	// 超过服务器的压缩阈值，双向都经过压缩
+	for _, msg := range []string{strings.Repeat("compress me ", 1000), "short"} {
+		if err := conn.WriteMessage(TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != msg {
+			t.Fatalf("unexpected echo of %d bytes", len(data))
+		}
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	closed := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		if r.URL.Path == "/bye" {
+			c.Close(websocket.StatusGoingAway, "bye")
+			return
+		}
+		_, _, err = c.Read(r.Context())
+		closed <- err
+	}))
+	defer srv.Close()
+
+	// 客户端发起关闭
+	conn, _, err := NewRequest().SetUrl(wsURL(srv)).DialWebSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.CloseWithCode(CloseNormal, "done"); err != nil {
+		t.Fatal(err)
+	}
+	if status := websocket.CloseStatus(<-closed); status != websocket.StatusNormalClosure {
+		t.Fatalf("server saw close status %d", status)
+	}
+	if err := conn.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrWebSocketClosed) {
+		t.Fatalf("write after close: %v", err)
+	}
+
+	// 服务器发起关闭
+	conn, _, err = NewRequest().SetUrl(wsURL(srv) + "/bye").DialWebSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = conn.ReadMessage()
+	var closeErr *WebSocketCloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" || !errors.Is(err, ErrWebSocketClosed) {
+		t.Fatalf("unexpected close error %v", err)
+	}
+	if _, _, err2 := conn.ReadMessage(); err2 != err {
+		t.Fatalf("read error should be sticky, got %v", err2)
+	}
+	conn.Close()
+}
+
+func TestWebSocketDeadlineAndLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		if r.URL.Path == "/big" {
+			c.Write(r.Context(), websocket.MessageBinary, make([]byte, 2048))
+		}
+		c.Read(r.Context())
+	}))
+	defer srv.Close()
+
+	conn, _, err := NewRequest().SetUrl(wsURL(srv)).DialWebSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, err = conn.ReadMessage()
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	conn.Close()
+
+	conn, _, err = NewRequest().SetUrl(wsURL(srv) + "/big").SetWebSocket(WebSocketConfig{ReadLimit: 1024}).DialWebSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.ReadMessage(); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected ErrBodyTooLarge, got %v", err)
+	}
+	conn.Close()
+}
+
+func TestWebSocketFrameLength(t *testing.T) {
+	// 握手后发送声明长度为 2^62 的帧，只跟随少量数据
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		buf.Write([]byte{0x82, 127, 0x40, 0, 0, 0, 0, 0, 0, 0})
+		buf.WriteString("partial")
+		buf.Flush()
+	}))
+	defer srv.Close()
+
+	for _, limit := range []int64{0, -1} {
+		conn, _, err := NewRequest().SetUrl(wsURL(srv)).SetWebSocket(WebSocketConfig{ReadLimit: limit}).DialWebSocket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if limit == 0 && conn.readLimit != defaultWebSocketReadLimit {
+			t.Fatalf("expected default read limit, got %d", conn.readLimit)
+		}
+		// 不按声明的长度预先分配内存，读到连接断开时返回错误
+		if _, _, err := conn.ReadMessage(); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("limit %d: expected unexpected EOF, got %v", limit, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		if r.URL.Path == "/silent" {
+			// 不读取就不会回应 ping
+			<-r.Context().Done()
+			return
+		}
+		for {
+			typ, data, err := c.Read(r.Context())
+			if err != nil {
+				return
+			}
+			c.Write(r.Context(), typ, data)
+		}
+	}))
+	defer srv.Close()
+
+	conn, _, err := NewRequest().SetUrl(wsURL(srv)).
+		SetWebSocket(WebSocketConfig{PingInterval: 20 * time.Millisecond}).DialWebSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		conn.WriteMessage(TextMessage, []byte("alive"))
+	}()
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "alive" {
+		t.Fatalf("keepalive: %q %v", data, err)
+	}
+	conn.Close()
+
+	conn, _, err = NewRequest().SetUrl(wsURL(srv) + "/silent").
+		SetWebSocket(WebSocketConfig{PingInterval: 20 * time.Millisecond}).DialWebSocket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.ReadMessage(); err == nil || !strings.Contains(err.Error(), "pong") {
+		t.Fatalf("expected pong timeout, got %v", err)
+	}
+}
+
+func TestWebSocketTLSAndProxy(t *testing.T) {
+	srv, _ := newEchoServer(t, true, nil)
+	serverPEM := serverCertPEM(srv)
+	addr, hits := startSocks5Server(t, "", "")
+
+	// 强制 HTTP/2 时握手仍使用 HTTP/1.1
+	for _, version := range []HTTPVersion{HTTPAuto, HTTP2} {
+		req := NewRequest().SetUrl(wsURL(srv)).AddCACertPEM(serverPEM).SetHTTPVersion(version).SetProxy("socks5://" + addr)
+		conn, _, err := req.DialWebSocket()
+		if err != nil {
+			t.Fatalf("%v: %v", version, err)
+		}
+		conn.WriteMessage(TextMessage, []byte("tls"))
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "tls" {
+			t.Fatalf("%v: %q %v", version, data, err)
+		}
+		conn.Close()
+	}
+	if atomic.LoadInt32(hits) != 2 {
+		t.Fatalf("expected 2 proxied connections, got %d", atomic.LoadInt32(hits))
+	}
+
+	if _, _, err := NewRequest().SetUrl(wsURL(srv)).DialWebSocket(); err == nil {
+		t.Fatal("expected certificate error")
+	}
+}
+
+func TestWebSocketHandshakeFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	conn, resp, err := NewRequest().SetUrl(wsURL(srv)).DialWebSocket()
+	if conn != nil || !errors.Is(err, ErrWebSocketHandshake) {
+		t.Fatalf("expected handshake error, got %v", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 response, got %v", resp)
+	}
+	resp.Body.Close()
+
+	if _, _, err := NewRequest().SetUrl("ftp://example.com").DialWebSocket(); err == nil {
+		t.Fatal("expected unsupported scheme error")
+	}
+}